@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+
+	"xpm-gen/internal/colors"
+	"xpm-gen/internal/importer"
+	"xpm-gen/internal/raster"
+)
+
+// boundaries on either side of the seam it is compared with
+// one on each side is too few, a periodic texture then lands above
+// -max-ratio whenever the wrap happens to fall on a busier boundary
+const seamBand = 3
+
+// color difference across one pair of opposite edges
+// seam: mean difference between the last and first row/column (what tiling puts side by side)
+// interior: mean difference between neighbouring rows/columns inside the image
+// nearby: the largest of the seamBand boundaries on either side of the seam
+// mismatched: fraction of seam pixel pairs whose colors differ at all
+// novel: fraction of the patches straddling the seam missing from the rest of the image
+// beside: the same for the patches right beside the seam, what is new just
+// because of the area the seam runs through
+type seamStats struct {
+	seam       float64
+	interior   float64
+	nearby     float64
+	mismatched float64
+	novel      float64
+	beside     float64
+}
+
+// how the seam compares with the boundaries right next to it
+// a real seam is a jump that its neighbours don't have, around 1 means
+// the wrap looks like any other row/column boundary in that area
+func (s seamStats) ratio() float64 {
+	if s.seam == 0 {
+		return 0
+	}
+	if s.nearby == 0 {
+		return math.Inf(1)
+	}
+	return s.seam / s.nearby
+}
+
+// reports how visible the wrap-around seams of xpm files are
+// takes: command line arguments after "check-tile"
+// returns: exit code (0 every file tiles, 1 a seam is visible, 2 bad usage or unreadable file)
+func runCheckTile(args []string) int {
+	fs := flag.NewFlagSet("check-tile", flag.ContinueOnError)
+	maxRatio := fs.Float64("max-ratio", 1.5, "Largest seam/nearby difference ratio still counted as seamless")
+	minDiffer := fs.Float64("min-differ", 10, "Percentage of edge pixels that must differ before a seam counts as visible")
+	maxNew := fs.Float64("max-new", 20, "Percentage points by which new patterns across the seam may exceed the share beside it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  xpm-gen check-tile [-max-ratio r] [-min-differ pct] [-max-new pct] file.xpm...\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, file := range fs.Args() {
+		data, err := importer.ReadXPM(file)
+		if err != nil {
+			logf("Error reading %s: %v\n", file, err)
+			return 2
+		}
+		palette := make([]color.NRGBA, len(data.PaletteKeys))
+		for i, k := range data.PaletteKeys {
+			// the reader already normalized every color
+			palette[i], _ = colors.Parse(data.Colors[k])
+		}
+		img := data.Image()
+
+		fmt.Printf("%s (%dx%d)\n", file, data.Width, data.Height)
+		seamless := true
+		for _, dir := range []struct {
+			name       string
+			horizontal bool
+		}{{"left/right", true}, {"top/bottom", false}} {
+			s := measureSeam(img, palette, dir.horizontal)
+			fmt.Printf("  %-10s seam %6.2f  nearby %6.2f  interior %6.2f  ratio %5.2f  %5.1f%% of edge pixels differ  %5.1f%% new patterns (%5.1f%% beside it)\n",
+				dir.name, s.seam, s.nearby, s.interior, s.ratio(), s.mismatched*100, s.novel*100, s.beside*100)
+			// a few differing pixels are just shapes crossing the edge
+			if s.ratio() > *maxRatio && s.mismatched*100 > *minDiffer {
+				seamless = false
+			}
+			// patterns that break off at the wrap, even without a color jump
+			if (s.novel-s.beside)*100 > *maxNew {
+				seamless = false
+			}
+		}
+		if seamless {
+			fmt.Printf("  seamless\n")
+		} else {
+			fmt.Printf("  visible seam\n")
+			code = 1
+		}
+	}
+	return code
+}
+
+// compares the wrapped edge pair with the neighbouring pairs inside the image
+// takes: image, parsed palette, true for the left/right seam, false for top/bottom
+// returns: seam statistics
+func measureSeam(img *raster.Image, palette []color.NRGBA, horizontal bool) seamStats {
+	h, w := img.Height, img.Width
+	if h == 0 || w == 0 {
+		return seamStats{}
+	}
+	at := func(line, pos int) color.NRGBA {
+		if horizontal {
+			return palette[img.At(pos, line)]
+		}
+		return palette[img.At(line, pos)]
+	}
+	lines, length := h, w
+	if !horizontal {
+		lines, length = w, h
+	}
+
+	var s seamStats
+	for line := 0; line < lines; line++ {
+		d := colorDiff(at(line, length-1), at(line, 0))
+		s.seam += d
+		if d > 0 {
+			s.mismatched++
+		}
+	}
+	s.seam /= float64(lines)
+	s.mismatched /= float64(lines)
+
+	// the same measurement for every boundary inside the image
+	for pos := 0; pos+1 < length; pos++ {
+		boundary := 0.0
+		for line := 0; line < lines; line++ {
+			boundary += colorDiff(at(line, pos), at(line, pos+1))
+		}
+		boundary /= float64(lines)
+		s.interior += boundary
+		if (pos < seamBand || pos+1+seamBand >= length) && boundary > s.nearby {
+			s.nearby = boundary
+		}
+	}
+	if length > 1 {
+		s.interior /= float64(length - 1)
+	}
+	s.novel, s.beside = measurePatches(img, horizontal)
+	return s
+}
+
+// side of the square patches compared by measurePatches
+const patchSize = 3
+
+// rows/columns on either side of the seam held out of the pattern dictionary
+const patchBand = 5
+
+// compares the patterns across the seam with the ones next to it
+// catches seams in textures like xor, where neighbouring pixels differ
+// everywhere and the color jump at the wrap is nothing special
+// patches within patchBand of the seam are left out of the dictionary, then
+// the ones straddling the seam are held against the ones beside it, so a
+// busy area that happens to sit at the edge is new on both sides alike
+// takes: image, true for the left/right seam, false for top/bottom
+// returns: novel and beside fractions (see seamStats), 0 for images too small to hold out the bands
+func measurePatches(img *raster.Image, horizontal bool) (novel, beside float64) {
+	lines, length := img.Height, img.Width
+	if !horizontal {
+		lines, length = length, lines
+	}
+	if lines < patchSize || length < 2*patchBand+patchSize {
+		return 0, 0
+	}
+	patch := func(line, pos int) [patchSize * patchSize]uint16 {
+		var p [patchSize * patchSize]uint16
+		for i := 0; i < patchSize; i++ {
+			for j := 0; j < patchSize; j++ {
+				l, q := line+i, (pos+j)%length
+				if horizontal {
+					p[i*patchSize+j] = uint16(img.At(q, l))
+				} else {
+					p[i*patchSize+j] = uint16(img.At(l, q))
+				}
+			}
+		}
+		return p
+	}
+
+	seen := map[[patchSize * patchSize]uint16]bool{}
+	for line := 0; line+patchSize <= lines; line++ {
+		for pos := patchBand; pos+patchSize+patchBand <= length; pos++ {
+			seen[patch(line, pos)] = true
+		}
+	}
+	// share of the patches straddling the boundary before column/row k
+	// (counted from the seam) that the dictionary lacks
+	unseen := func(k int) float64 {
+		n, missing := 0, 0
+		for line := 0; line+patchSize <= lines; line++ {
+			for pos := k - patchSize + 1; pos < k; pos++ {
+				n++
+				if !seen[patch(line, (pos+length)%length)] {
+					missing++
+				}
+			}
+		}
+		return float64(missing) / float64(n)
+	}
+	// the boundaries beside the seam whose patches still lie in the held out bands
+	for _, k := range []int{-3, -2, 2, 3} {
+		beside = max(beside, unseen(k))
+	}
+	return unseen(0), beside
+}
+
+// mean absolute channel difference, 0 (same) to 255
+// transparency counts as a channel so "None" next to a color shows up
+func colorDiff(a, b color.NRGBA) float64 {
+	abs := func(x, y uint8) float64 { return math.Abs(float64(x) - float64(y)) }
+	return (abs(a.R, b.R) + abs(a.G, b.G) + abs(a.B, b.B) + abs(a.A, b.A)) / 4
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xpm-gen/internal/exporter"
+	"xpm-gen/internal/raster"
+)
+
+var checkPalette = []string{"#000000", "#FF0000", "#00FF00", "#0000FF", "#FFFF00", "#00FFFF", "#FF00FF", "#FFFFFF"}
+
+// random colors, repeated cols x rows times so the sheet wraps by construction
+func noiseSheet(rng *rand.Rand, w, h, cols, rows int) *raster.Image {
+	tile := raster.New(w, h, checkPalette)
+	for i := range tile.Pix {
+		tile.Pix[i] = uint16(rng.Intn(len(checkPalette)))
+	}
+	return tile.Tile(cols, rows)
+}
+
+// random values smoothed with a box blur that wraps around the edges,
+// grainy like the simulations, where most small patterns occur only once
+func blurredNoise(rng *rand.Rand, w, h int) *raster.Image {
+	field := make([]float64, w*h)
+	for i := range field {
+		field[i] = rng.Float64()
+	}
+	blurred := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					blurred[y*w+x] += field[(y+dy+h)%h*w+(x+dx+w)%w] / 9
+				}
+			}
+		}
+	}
+	img := raster.New(w, h, checkPalette)
+	for i, v := range blurred {
+		// the average of nine uniform values rarely leaves 0.2..0.8
+		img.Pix[i] = uint16(min(max(int((v-0.2)/0.6*float64(len(checkPalette))), 0), len(checkPalette)-1))
+	}
+	return img
+}
+
+// banded sine interference, periods across the width/height
+// whole periods wrap around, anything else leaves a seam
+func waves(rng *rand.Rand, w, h int, periodsX, periodsY float64) *raster.Image {
+	img := raster.New(w, h, checkPalette)
+	phase := rng.Float64() * 2 * math.Pi
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u, v := 2*math.Pi*periodsX*float64(x)/float64(w), 2*math.Pi*periodsY*float64(y)/float64(h)
+			f := (math.Sin(u+phase) + math.Sin(v) + math.Sin(u+v)) / 3 // -1..1
+			img.Set(x, y, min(int((f+1)/2*float64(len(checkPalette))), len(checkPalette)-1))
+		}
+	}
+	return img
+}
+
+// munching squares that don't fit the image, so the wrap breaks the pattern
+func xorSquares(w, h int) *raster.Image {
+	img := raster.New(w, h, checkPalette)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, ((x+37)^(y+101))%len(checkPalette))
+		}
+	}
+	return img
+}
+
+// moves the seam of a wrapping image into its middle
+func roll(img *raster.Image, dx, dy int) *raster.Image {
+	return img.Tile(2, 2).SubImage(dx, dy, img.Width, img.Height)
+}
+
+func TestCheckTile(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		img  *raster.Image
+		want int
+	}{
+		{"rolled noise", roll(noiseSheet(rng, 96, 96, 1, 1), 37, 29), 0},
+		{"rolled noise sheet", roll(noiseSheet(rng, 24, 20, 4, 3), 37, 29), 0},
+		{"rolled blurred noise", roll(blurredNoise(rng, 96, 96), 50, 11), 0},
+		{"rolled whole waves", roll(waves(rng, 96, 80, 3, 2), 41, 17), 0},
+		{"half waves", waves(rng, 96, 80, 2.5, 1.5), 1},
+		{"xor", xorSquares(100, 70), 1},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name+".xpm")
+			f, err := os.Create(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := exporter.WriteXPM(f, tt.img, nil); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+			if got := runCheckTile([]string{file}); got != tt.want {
+				t.Errorf("exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/exporter"
+	"xpm-gen/internal/importer"
+	"xpm-gen/internal/palette"
+	"xpm-gen/internal/quantize"
+	"xpm-gen/internal/raster"
+)
+
+// turns png, gif and jpeg files into xpms
+// xpm inputs keep their palette and are only run through -transform
+// takes: command line arguments after "convert" (or "import")
+// returns: exit code (0 every file converted, 1 a conversion failed, 2 bad usage)
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	numColors := fs.Int("colors", 16, "Palette size to reduce to, not counting transparency")
+	method := fs.String("method", "median-cut", "Quantization method: "+strings.Join(quantize.Methods, " or "))
+	paletteSpec := fs.String("palette", "", "Map onto this palette instead of building one (a -palette name or file)")
+	alpha := fs.Int("alpha", 128, "Pixels with alpha below this (0-255) become transparent 'None'")
+	output := fs.String("o", "", "Write the XPM to this exact path ('-' for stdout, single input only)")
+	outDir := fs.String("outdir", "", "Directory for converted files (created if missing)")
+	name := fs.String("name", "", "File name template (default '{name}', the input's base name)")
+	overwrite := fs.String("overwrite", "", "What to do when the output exists: 'unique', 'replace' or 'fail'")
+	format := fs.String("format", "", "Also export the result as an image: 'png', 'gif' or 'bmp'")
+	transform := fs.String("transform", "", "Reshape the result keeping its palette, e.g. 'scale:4,rotate:90,flip:h,tile:3x3,crop:0,0,64,64'")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  xpm-gen convert [-colors n] [-method m] [-palette p] [-alpha a] [-transform t] [-o file] image...\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := quantize.Options{Colors: *numColors, Method: *method}
+	switch {
+	case *numColors < 1:
+		logf("Error: -colors must be at least 1\n")
+		return 2
+	case !slices.Contains(quantize.Methods, *method):
+		logf("Error: Unknown method '%s' (want one of %s)\n", *method, strings.Join(quantize.Methods, ", "))
+		return 2
+	case *alpha < 0 || *alpha > 255:
+		logf("Error: -alpha must be between 0 and 255\n")
+		return 2
+	case *format != "" && !exporter.IsImageFormat(*format):
+		logf("Error: Unknown format '%s' (want one of %s)\n", *format, strings.Join(exporter.ImageFormats, ", "))
+		return 2
+	case *output != "" && fs.NArg() > 1:
+		logf("Error: -o names a single file, use -outdir and -name when converting several images\n")
+		return 2
+	}
+	transforms, err := raster.ParseTransforms(*transform)
+	if err != nil {
+		logf("Error: %v\n", err)
+		return 2
+	}
+	opts.Alpha = uint8(*alpha)
+	if *paletteSpec != "" {
+		p, err := palette.Resolve(*paletteSpec)
+		if err != nil {
+			logf("Error: %v\n", err)
+			return 2
+		}
+		opts.Palette = p.Colors
+	}
+
+	out := exporter.Output{Path: *output, Dir: *outDir, Template: *name, Overwrite: *overwrite}
+	if err := out.Validate(); err != nil {
+		logf("Error: %v\n", err)
+		return 2
+	}
+	if out.Path == "-" {
+		if *format != "" {
+			logf("Error: -format writes next to the XPM file and can't be combined with -o -\n")
+			return 2
+		}
+		logOut = os.Stderr
+	}
+	if out.Path == "" && out.Template == "" {
+		out.Template = "{name}"
+	}
+
+	code := 0
+	for _, file := range fs.Args() {
+		if err := convertFile(file, opts, transforms, out, *format); err != nil {
+			logf("Error: %v\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// quantizes one image, reshapes it and saves it as xpm
+func convertFile(file string, opts quantize.Options, transforms []raster.Transform, out exporter.Output, format string) error {
+	indexed, chars, err := readIndexed(file, opts)
+	if err != nil {
+		return err
+	}
+	indexed, err = indexed.Transform(transforms)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	cfg := config.Config{
+		Width:     indexed.Width,
+		Height:    indexed.Height,
+		Algorithm: "converted",
+		Colors:    indexed.Palette,
+	}
+	// {name} is the original filename base
+	vars := exporter.TemplateVars(cfg)
+	vars["name"] = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	fileName, err := exporter.SaveXPM(out, vars, indexed, chars)
+	if err != nil {
+		return fmt.Errorf("writing XPM: %w", err)
+	}
+	logf("Success! Converted %s to %s (%dx%d, %d colors)\n", file, fileName, cfg.Width, cfg.Height, len(indexed.Palette))
+
+	if format != "" {
+		exportImage(out, fileName, indexed, format)
+	}
+	return nil
+}
+
+// loads one input as an indexed image
+// xpms are indexed already and keep their palette and character codes,
+// everything else is quantized
+// takes: path, quantizer options
+// returns: image, character codes to keep (nil for quantized images), error
+func readIndexed(file string, opts quantize.Options) (*raster.Image, []string, error) {
+	if strings.EqualFold(filepath.Ext(file), ".xpm") {
+		data, err := importer.ReadXPM(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data.Image(), data.PaletteKeys, nil
+	}
+	img, err := importer.ReadImage(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexed, err := quantize.Image(img, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return indexed, nil, nil
+}
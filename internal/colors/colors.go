@@ -0,0 +1,133 @@
+package colors
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// parses any color spec X11 understands
+// accepts:
+//   - "None" (fully transparent)
+//   - names from rgb.txt, case and spaces ignored ("gray50", "DarkSlateBlue", "dark slate blue")
+//   - #RGB, #RRGGBB, #RRRGGGBBB, #RRRRGGGGBBBB
+//   - rgb:R/G/B with 1-4 hex digits per channel
+//   - rgbi:R/G/B with floats between 0 and 1
+//
+// channels with more or less than 8 bits are scaled, so #FFF and
+// #FFFF0000FFFF come out as #FFFFFF and #FF00FF
+// takes: color spec
+// returns: color or error
+func Parse(s string) (color.NRGBA, error) {
+	spec := strings.TrimSpace(s)
+	lower := strings.ToLower(spec)
+
+	switch {
+	case lower == "none":
+		return color.NRGBA{}, nil
+	case strings.HasPrefix(spec, "#"):
+		return parseHash(spec)
+	case strings.HasPrefix(lower, "rgb:"):
+		return parseRGB(spec)
+	case strings.HasPrefix(lower, "rgbi:"):
+		return parseRGBI(spec)
+	}
+	if rgb, ok := lookupName(spec); ok {
+		return color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}, nil
+	}
+	return color.NRGBA{}, fmt.Errorf("unknown color %q", s)
+}
+
+// formats a color the way xpm-gen writes palettes
+// takes: color
+// returns: "#RRGGBB", or "None" when fully transparent
+func Hex(c color.NRGBA) string {
+	if c.A == 0 {
+		return "None"
+	}
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// parses a spec and formats it back as "#RRGGBB" or "None"
+// handy for any palette input coming from users or files
+func Normalize(s string) (string, error) {
+	c, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return Hex(c), nil
+}
+
+// looks a name up in the rgb.txt table
+func lookupName(name string) ([3]uint8, bool) {
+	key := strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	rgb, ok := x11Names[key]
+	return rgb, ok
+}
+
+// #RGB, #RRGGBB, #RRRGGGBBB or #RRRRGGGGBBBB
+func parseHash(s string) (color.NRGBA, error) {
+	hex := s[1:]
+	if len(hex) == 0 || len(hex)%3 != 0 || len(hex) > 12 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: want #RGB, #RRGGBB, #RRRGGGBBB or #RRRRGGGGBBBB", s)
+	}
+	n := len(hex) / 3
+	var ch [3]uint8
+	for i := range ch {
+		v, err := scaleHex(hex[i*n : (i+1)*n])
+		if err != nil {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+		}
+		ch[i] = v
+	}
+	return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: 255}, nil
+}
+
+// rgb:R/G/B, each channel 1-4 hex digits
+func parseRGB(s string) (color.NRGBA, error) {
+	parts := strings.Split(s[len("rgb:"):], "/")
+	if len(parts) != 3 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: want rgb:R/G/B", s)
+	}
+	var ch [3]uint8
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 4 {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q: channels need 1-4 hex digits", s)
+		}
+		v, err := scaleHex(p)
+		if err != nil {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+		}
+		ch[i] = v
+	}
+	return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: 255}, nil
+}
+
+// rgbi:R/G/B, each channel a float from 0 to 1
+func parseRGBI(s string) (color.NRGBA, error) {
+	parts := strings.Split(s[len("rgbi:"):], "/")
+	if len(parts) != 3 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: want rgbi:R/G/B", s)
+	}
+	var ch [3]uint8
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || v > 1 {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q: channels must be between 0 and 1", s)
+		}
+		ch[i] = uint8(v*255 + 0.5)
+	}
+	return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: 255}, nil
+}
+
+// scales a 1-4 digit hex channel to 8 bits
+// e.g. "F" -> 255, "8000" -> 128
+func scaleHex(h string) (uint8, error) {
+	v, err := strconv.ParseUint(h, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad hex digits %q", h)
+	}
+	max := uint64(1)<<(4*len(h)) - 1
+	return uint8((v*255 + max/2) / max), nil
+}
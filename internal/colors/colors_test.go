@@ -0,0 +1,75 @@
+package colors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"None", "None"},
+		{"none", "None"},
+		{"red", "#FF0000"},
+		{"DarkSlateBlue", "#483D8B"},
+		{"dark slate blue", "#483D8B"},
+		{"gray50", "#7F7F7F"},
+		{"Grey100", "#FFFFFF"},
+		{"  navy  ", "#000080"},
+		{"#fff", "#FFFFFF"},
+		{"#123", "#112233"},
+		{"#12aB34", "#12AB34"},
+		{"#800800800", "#808080"},
+		{"#FFFF0000FFFF", "#FF00FF"},
+		{"#80008000FFFF", "#8080FF"},
+		{"rgb:f/0/8", "#FF0088"},
+		{"rgb:ff/80/00", "#FF8000"},
+		{"RGB:ffff/0/1", "#FF0011"},
+		{"rgb:8000/0/0", "#800000"},
+		{"rgbi:1/0/0.5", "#FF0080"},
+		{"rgbi:0.2/0.4/0.6", "#336699"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Normalize(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %s, want %s", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		msg  string // part of the message
+	}{
+		{"", "unknown color"},
+		{"notacolor", "unknown color"},
+		{"#", "want #RGB"},
+		{"#12", "want #RGB"},
+		{"#1234", "want #RGB"},
+		{"#1234567890ABC", "want #RGB"},
+		{"#GGG", "bad hex digits"},
+		{"rgb:1/2", "want rgb:R/G/B"},
+		{"rgb:1/2/", "1-4 hex digits"},
+		{"rgb:12345/0/0", "1-4 hex digits"},
+		{"rgb:x/0/0", "bad hex digits"},
+		{"rgbi:1/0", "want rgbi:R/G/B"},
+		{"rgbi:1.5/0/0", "between 0 and 1"},
+		{"rgbi:-0.1/0/0", "between 0 and 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("Parse(%q) error = %v, want one containing %q", tt.spec, err, tt.msg)
+			}
+		})
+	}
+}
@@ -1,14 +1,28 @@
 package config
 
+import "xpm-gen/internal/raster"
+
 // holds configuration for the texture generation
 // width/height: dimensions of the output
 // algorithm: selected generation method
 // colors: palette of hex codes
-// chars: xpm mapping characters
+// seed: source for every random decision, same seed + params = same output
+// params: generator specific settings from -param name=value
+// tileable: opposite edges must line up so the texture repeats without seams
+// dither: how continuous generators spread values between palette steps (see generator.DitherModes, "" = none)
+// workers: goroutines for the simulation steps (0 = one per cpu), output is the same for any value
+// onframe: called by time-evolving generators with a snapshot every frameevery steps (nil = off)
 type Config struct {
 	Width     int
 	Height    int
 	Algorithm string
 	Colors    []string
-	Chars     []string
-}
\ No newline at end of file
+	Seed      int64
+	Params    map[string]string
+	Tileable  bool
+	Dither    string
+	Workers   int
+
+	OnFrame    func(step int, img *raster.Image)
+	FrameEvery int
+}
@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+
+	"xpm-gen/internal/raster"
+)
+
+// collects simulation frames and writes them as an animated gif
+// frames are kept indexed (one byte per pixel), so palettes are limited to 256 colors
+type Animation struct {
+	Delay     int // hundredths of a second per frame
+	LoopCount int // 0 loops forever, -1 plays once, n plays n extra times
+	colors    []string
+	palette   color.Palette
+	frames    []*image.Paletted
+}
+
+// prepares an empty animation for a palette
+// takes: palette of every frame, frame delay, loop count
+// returns: animation or error when the palette can't be indexed
+func NewAnimation(colors []string, delay, loop int) (*Animation, error) {
+	if len(colors) > 256 {
+		return nil, fmt.Errorf("animations need at most 256 colors, palette has %d", len(colors))
+	}
+	palette, err := raster.ParsePalette(colors)
+	if err != nil {
+		return nil, err
+	}
+	return &Animation{Delay: delay, LoopCount: loop, colors: colors, palette: palette}, nil
+}
+
+// appends a frame
+// mutates: animation
+func (a *Animation) Add(img *raster.Image) {
+	a.frames = append(a.frames, img.PalettedWith(a.palette))
+}
+
+// number of captured frames
+func (a *Animation) Len() int { return len(a.frames) }
+
+// writes the animated gif next to the xpm as <name>_anim.gif
+// takes: output settings (for the overwrite policy), xpm filename
+// returns: gif filename and error or nil (also when the policy forbids replacing it)
+// mutates: filesystem
+func (a *Animation) SaveGIF(o Output, fileName string) (string, error) {
+	if len(a.frames) == 0 {
+		return "", fmt.Errorf("no frames captured")
+	}
+	anim := &gif.GIF{LoopCount: a.LoopCount}
+	for _, img := range a.frames {
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, a.Delay)
+		// every frame covers the whole canvas, so "None" pixels must not
+		// show the previous frame through
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	f, gifName, err := o.CreateBeside(fileName, "_anim.gif")
+	if err != nil {
+		return "", err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return "", err
+	}
+	return gifName, f.Close()
+}
+
+// writes every frame as <name>_frame_0001.xpm, <name>_frame_0002.xpm...
+// takes: output settings (for the overwrite policy), xpm filename
+// returns: glob pattern matching the frames and error or nil (also when the policy forbids replacing one)
+// mutates: filesystem
+func (a *Animation) SaveFrames(o Output, fileName string) (string, error) {
+	for i, img := range a.frames {
+		f, _, err := o.CreateBeside(fileName, fmt.Sprintf("_frame_%04d.xpm", i+1))
+		if err != nil {
+			return "", err
+		}
+		if err := WriteXPM(f, raster.FromPaletted(img, a.colors), nil); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(fileName, ".xpm") + "_frame_*.xpm", nil
+}
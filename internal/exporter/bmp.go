@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// encodes an image as a windows bitmap
+// opaque paletted images become 8-bit indexed bmps, everything else
+// is written as 32-bit bgra with a v4 header so transparency survives
+// takes: writer, image
+// returns: error or nil
+func encodeBMP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if p, ok := img.(*image.Paletted); ok && len(p.Palette) <= 256 && isOpaque(p) {
+		return encodeBMP8(w, p, width, height)
+	}
+
+	const headerSize = 14 + 108
+	rowSize := width * 4
+	imageSize := rowSize * height
+
+	bw := bufio.NewWriter(w)
+	writeFileHeader(bw, headerSize+imageSize, headerSize)
+	le(bw,
+		uint32(108), int32(width), int32(height), uint16(1), uint16(32),
+		uint32(3), // BI_BITFIELDS
+		uint32(imageSize), int32(2835), int32(2835), uint32(0), uint32(0),
+		// channel masks: r, g, b, a
+		uint32(0x00FF0000), uint32(0x0000FF00), uint32(0x000000FF), uint32(0xFF000000),
+		uint32(0x73524742), // 'sRGB' colour space
+		[36]byte{},         // endpoints (unused for srgb)
+		[3]uint32{},        // gamma (unused for srgb)
+	)
+
+	row := make([]byte, rowSize)
+	// bmp rows are stored bottom-up
+	for y := height - 1; y >= 0; y-- {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			if a > 0 {
+				// un-premultiply, bmp alpha is straight
+				r, g, b = r*0xFFFF/a, g*0xFFFF/a, b*0xFFFF/a
+			}
+			row[x*4+0] = uint8(b >> 8)
+			row[x*4+1] = uint8(g >> 8)
+			row[x*4+2] = uint8(r >> 8)
+			row[x*4+3] = uint8(a >> 8)
+		}
+		bw.Write(row)
+	}
+	return bw.Flush()
+}
+
+// encodes an opaque paletted image as an 8-bit indexed bmp
+func encodeBMP8(w io.Writer, p *image.Paletted, width, height int) error {
+	headerSize := 14 + 40 + 4*len(p.Palette)
+	// rows are padded to a multiple of 4 bytes
+	rowSize := (width + 3) &^ 3
+	imageSize := rowSize * height
+
+	bw := bufio.NewWriter(w)
+	writeFileHeader(bw, headerSize+imageSize, headerSize)
+	le(bw,
+		uint32(40), int32(width), int32(height), uint16(1), uint16(8),
+		uint32(0), // BI_RGB
+		uint32(imageSize), int32(2835), int32(2835), uint32(len(p.Palette)), uint32(0),
+	)
+	for _, c := range p.Palette {
+		r, g, b, _ := c.RGBA()
+		bw.Write([]byte{uint8(b >> 8), uint8(g >> 8), uint8(r >> 8), 0})
+	}
+
+	row := make([]byte, rowSize)
+	for y := height - 1; y >= 0; y-- {
+		copy(row, p.Pix[y*p.Stride:y*p.Stride+width])
+		bw.Write(row)
+	}
+	return bw.Flush()
+}
+
+// writes the 14 byte "BM" file header
+func writeFileHeader(w io.Writer, fileSize, pixelOffset int) {
+	w.Write([]byte("BM"))
+	le(w, uint32(fileSize), uint32(0), uint32(pixelOffset))
+}
+
+// writes each value little-endian
+// errors are picked up by the final bufio flush
+func le(w io.Writer, values ...any) {
+	for _, v := range values {
+		binary.Write(w, binary.LittleEndian, v)
+	}
+}
+
+// reports whether every palette entry is fully opaque
+func isOpaque(p *image.Paletted) bool {
+	for _, c := range p.Palette {
+		if _, _, _, a := c.RGBA(); a != 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
@@ -1,73 +1,100 @@
 package exporter
 
 import (
+	"bufio"
 	"fmt"
-	"os"
-	"os/exec"
-	"time"
-	"xpm-gen/internal/config"
+	"io"
+	"strings"
+	"xpm-gen/internal/raster"
 )
 
-// converts grid to xpm string
-// does all the header stuff, palette setup, and dumps pixel data for xpm3
-// takes: grid (2d array), config
-// returns: xpm content string
-func GridToXPM(grid [][]int, cfg config.Config) string {
-	header := "/* XPM */\n"
-	header += "static char * texture[] = {\n"
-	header += fmt.Sprintf("\"%d %d %d %d\",\n", cfg.Width, cfg.Height, len(cfg.Colors), 1)
+// characters that are safe inside an xpm string literal
+// everything printable except space, '"' and '\\', letters first so small
+// palettes keep the familiar a, b, c... mapping
+const xpmAlphabet = "abcdefghijklmnopqrstuvwxyz" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"0123456789" +
+	"!#$%&'()*+,-./:;<=>?@[]^_`{|}~"
 
-	for i, color := range cfg.Colors {
-		if color == "None" {
-			header += fmt.Sprintf("\"%s c None\",\n", cfg.Chars[i])
-		} else {
-			header += fmt.Sprintf("\"%s c %s\",\n", cfg.Chars[i], color)
-		}
+// builds a character table big enough for n colors
+// picks the smallest chars-per-pixel that fits, so 92 colors use one char,
+// 8464 use two, and so on
+// takes: number of colors
+// returns: one code per color, chars per pixel
+func CharTable(n int) ([]string, int) {
+	cpp := 1
+	for capacity := len(xpmAlphabet); capacity < n; capacity *= len(xpmAlphabet) {
+		cpp++
 	}
-
-	for y := 0; y < cfg.Height; y++ {
-		line := "\""
-		for x := 0; x < cfg.Width; x++ {
-			line += cfg.Chars[grid[y][x]]
+	chars := make([]string, n)
+	code := make([]byte, cpp)
+	for i := 0; i < n; i++ {
+		// write i in base len(alphabet), most significant char first
+		v := i
+		for j := cpp - 1; j >= 0; j-- {
+			code[j] = xpmAlphabet[v%len(xpmAlphabet)]
+			v /= len(xpmAlphabet)
 		}
-		line += "\",\n"
-		header += line
+		chars[i] = string(code)
 	}
-
-	header += "};\n"
-	return header
+	return chars, cpp
 }
 
-// saves content to a unique filename
-// checks if file exists to avoid overwriting stuff, increments counter if needed
-// takes: algorithm name, content string
-// returns: filename used
-// mutates: filesystem (creates new .xpm file)
-func SaveUniqueFile(algo string, content string) string {
-	for i := 0; i < 1000; i++ {
-		name := fmt.Sprintf("xpmgen_%s_%d.xpm", algo, i)
-		if _, err := os.Stat(name); os.IsNotExist(err) {
-			err := os.WriteFile(name, []byte(content), 0644)
-			if err != nil {
-				fmt.Printf("Error writing file: %v\n", err)
-				os.Exit(1)
+// picks the character codes for the palette
+// keeps the given chars when they cover the palette with one fixed width (e.g.
+// the original keys of a recolored file), otherwise generates a table
+// takes: palette, wanted chars (may be nil)
+// returns: one code per color, chars per pixel
+func paletteChars(palette, chars []string) ([]string, int) {
+	if len(chars) >= len(palette) && len(palette) > 0 {
+		cpp := len(chars[0])
+		seen := make(map[string]bool, len(palette))
+		usable := cpp > 0
+		for _, c := range chars[:len(palette)] {
+			if len(c) != cpp || seen[c] || strings.ContainsAny(c, "\"\\") {
+				usable = false
+				break
 			}
-			return name
+			seen[c] = true
+		}
+		if usable {
+			return chars[:len(palette)], cpp
 		}
 	}
-	timestamp := time.Now().Unix()
-	name := fmt.Sprintf("xpmgen_%s_%d.xpm", algo, timestamp)
-	os.WriteFile(name, []byte(content), 0644)
-	return name
+	return CharTable(len(palette))
 }
 
-// converts xpm to png using imagemagick
-// calls external 'convert' command
-// takes: filename string
-// returns: error or nil
-// mutates: filesystem (creates new .png file via subprocess)
-func ConvertToPNG(fileName string) error {
-	pngName := fileName[:len(fileName)-4] + ".png"
-	cmd := exec.Command("convert", fileName, pngName)
-	return cmd.Run()
-}
\ No newline at end of file
+// streams the image to w as xpm3
+// does all the header stuff, palette setup, and dumps pixel data
+// output is buffered, so large textures never live in memory as one string
+// chars per pixel grows automatically with the palette size
+// takes: writer, image, character codes to keep (nil to generate them)
+// returns: first write error or nil
+func WriteXPM(w io.Writer, img *raster.Image, chars []string) error {
+	chars, cpp := paletteChars(img.Palette, chars)
+	bw := bufio.NewWriterSize(w, 64*1024)
+
+	fmt.Fprintf(bw, "/* XPM */\n")
+	fmt.Fprintf(bw, "static char * texture[] = {\n")
+	fmt.Fprintf(bw, "\"%d %d %d %d\",\n", img.Width, img.Height, len(img.Palette), cpp)
+
+	for i, color := range img.Palette {
+		if color == "None" {
+			fmt.Fprintf(bw, "\"%s c None\",\n", chars[i])
+		} else {
+			fmt.Fprintf(bw, "\"%s c %s\",\n", chars[i], color)
+		}
+	}
+
+	for y := 0; y < img.Height; y++ {
+		bw.WriteByte('"')
+		for _, v := range img.Row(y) {
+			bw.WriteString(chars[v])
+		}
+		bw.WriteString("\",\n")
+	}
+
+	bw.WriteString("};\n")
+	// bufio keeps the first error, flush reports it
+	return bw.Flush()
+}
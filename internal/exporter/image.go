@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"strings"
+
+	"xpm-gen/internal/raster"
+)
+
+// image formats we can encode natively (no imagemagick needed)
+var ImageFormats = []string{"png", "gif", "bmp"}
+
+// checks if a format name is one of ImageFormats
+func IsImageFormat(format string) bool {
+	for _, f := range ImageFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// encodes an image in the requested format
+// takes: writer, image, format ("png", "gif" or "bmp")
+// returns: error or nil
+func EncodeImage(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "bmp":
+		return encodeBMP(w, img)
+	}
+	return fmt.Errorf("unknown image format %q (want one of %s)", format, strings.Join(ImageFormats, ", "))
+}
+
+// writes the image as a png/gif/bmp file next to the xpm
+// swaps the .xpm extension for the format name
+// takes: output settings (for the overwrite policy), xpm filename, image, format
+// returns: image filename and error or nil (also when the policy forbids replacing it)
+// mutates: filesystem (creates new image file)
+func ExportImage(o Output, fileName string, m *raster.Image, format string) (string, error) {
+	img, err := m.Image()
+	if err != nil {
+		return "", err
+	}
+	f, imgName, err := o.CreateBeside(fileName, "."+format)
+	if err != nil {
+		return "", err
+	}
+	if err := EncodeImage(f, img, format); err != nil {
+		f.Close()
+		return "", err
+	}
+	return imgName, f.Close()
+}
@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xpm-gen/internal/raster"
+)
+
+func TestExportImageOverwrite(t *testing.T) {
+	img := raster.New(2, 2, []string{"#000000", "#FFFFFF"})
+	img.Set(1, 1, 1)
+	tests := []struct {
+		policy  string
+		wantErr bool
+		want    string // file written, relative to the temp dir
+	}{
+		{OverwriteFail, true, ""},
+		{OverwriteReplace, false, "t.png"},
+		{OverwriteUnique, false, "t_1.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			xpm, png := filepath.Join(dir, "t.xpm"), filepath.Join(dir, "t.png")
+			if err := os.WriteFile(png, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			name, err := ExportImage(Output{Path: xpm, Overwrite: tt.policy}, xpm, img, "png")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("wrote %s, want an error", name)
+				}
+				if old, _ := os.ReadFile(png); string(old) != "old" {
+					t.Errorf("existing t.png was changed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != filepath.Join(dir, tt.want) {
+				t.Errorf("wrote %s, want %s", name, tt.want)
+			}
+			if data, _ := os.ReadFile(name); string(data) == "old" || len(data) == 0 {
+				t.Errorf("%s holds no png", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+// how to treat a file that already exists at the output path
+const (
+	OverwriteUnique  = "unique"  // pick the next free {n}, or add a _n suffix
+	OverwriteReplace = "replace" // overwrite the existing file
+	OverwriteFail    = "fail"    // return an error
+)
+
+// every supported overwrite policy
+var OverwritePolicies = []string{OverwriteUnique, OverwriteReplace, OverwriteFail}
+
+// the template used when nothing else is configured
+const DefaultTemplate = "xpmgen_{algo}_{n}"
+
+// describes where output files go
+// path: exact file path, "-" means stdout
+// dir: directory for templated names (created if missing)
+// template: file name with {algo}, {seed}, {w}, {h}, {name} and {n} placeholders
+// overwrite: one of OverwritePolicies, empty means replace for path and unique otherwise
+type Output struct {
+	Path      string
+	Dir       string
+	Template  string
+	Overwrite string
+}
+
+// builds the standard template variables for a config
+// takes: config
+// returns: placeholder name -> value
+func TemplateVars(cfg config.Config) map[string]string {
+	return map[string]string{
+		"algo": cfg.Algorithm,
+		"seed": strconv.FormatInt(cfg.Seed, 10),
+		"w":    strconv.Itoa(cfg.Width),
+		"h":    strconv.Itoa(cfg.Height),
+	}
+}
+
+// checks the output settings before anything is generated
+// returns: error describing the first problem or nil
+func (o Output) Validate() error {
+	if o.Path != "" && (o.Dir != "" || o.Template != "") {
+		return errors.New("-o can't be combined with -outdir or -name")
+	}
+	switch o.Overwrite {
+	case "", OverwriteUnique, OverwriteReplace, OverwriteFail:
+	default:
+		return fmt.Errorf("unknown overwrite policy %q (want one of %s)", o.Overwrite, strings.Join(OverwritePolicies, ", "))
+	}
+	if o.Template != "" {
+		if _, err := expandTemplate(o.Template, nil, 0, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// opens the output file according to the settings and overwrite policy
+// the caller must close the file
+// takes: template variables
+// returns: open file, path used, error or nil
+// mutates: filesystem (creates the file and output directory)
+func (o Output) Create(vars map[string]string) (*os.File, string, error) {
+	policy := o.policy()
+	if o.Path != "" {
+		return createWithPolicy(o.Path, policy)
+	}
+
+	tmpl := o.Template
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	if o.Dir != "" {
+		if err := os.MkdirAll(o.Dir, 0755); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// templates with {n} count up until a free name is found
+	if policy == OverwriteUnique && strings.Contains(tmpl, "{n}") {
+		for n := 0; n < 1000; n++ {
+			name, err := o.templatePath(tmpl, vars, n)
+			if err != nil {
+				return nil, "", err
+			}
+			f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err == nil {
+				return f, name, nil
+			}
+			if !errors.Is(err, fs.ErrExist) {
+				return nil, "", err
+			}
+		}
+		// give up counting, fall back to a timestamp
+		name, err := o.templatePath(strings.ReplaceAll(tmpl, "{n}", strconv.FormatInt(time.Now().Unix(), 10)), vars, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		return createWithPolicy(name, policy)
+	}
+
+	name, err := o.templatePath(tmpl, vars, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return createWithPolicy(name, policy)
+}
+
+// opens a file that belongs with a saved xpm (.algo, .gif ...) under the same overwrite policy
+// the caller must close the file
+// takes: path of the xpm, suffix replacing its .xpm extension
+// returns: open file, path used, error or nil
+// mutates: filesystem (creates the file)
+func (o Output) CreateBeside(xpmName, suffix string) (*os.File, string, error) {
+	return o.CreateFile(strings.TrimSuffix(xpmName, ".xpm") + suffix)
+}
+
+// opens a file at an exact path (-save-palette ...) under the same overwrite policy
+// the caller must close the file
+// takes: path
+// returns: open file, path used (unique may add _1, _2...), error or nil
+// mutates: filesystem (creates the file)
+func (o Output) CreateFile(name string) (*os.File, string, error) {
+	return createWithPolicy(name, o.policy())
+}
+
+// the overwrite policy in effect, replace for -o and unique otherwise when unset
+func (o Output) policy() string {
+	switch {
+	case o.Overwrite != "":
+		return o.Overwrite
+	case o.Path != "":
+		return OverwriteReplace
+	}
+	return OverwriteUnique
+}
+
+// expands the template and places it in the output dir with a .xpm extension
+func (o Output) templatePath(tmpl string, vars map[string]string, n int) (string, error) {
+	name, err := expandTemplate(tmpl, vars, n, false)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Ext(name) != ".xpm" {
+		name += ".xpm"
+	}
+	return filepath.Join(o.Dir, name), nil
+}
+
+// replaces {placeholders} in a template
+// check mode only verifies the syntax and placeholder names
+func expandTemplate(tmpl string, vars map[string]string, n int, check bool) (string, error) {
+	var sb strings.Builder
+	rest := tmpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed '{' in name template %q", tmpl)
+		}
+		key := rest[open+1 : open+end]
+		sb.WriteString(rest[:open])
+		switch key {
+		case "n":
+			sb.WriteString(strconv.Itoa(n))
+		case "algo", "seed", "w", "h", "name":
+			if v, ok := vars[key]; ok || check {
+				sb.WriteString(v)
+			} else {
+				return "", fmt.Errorf("placeholder {%s} is not available here", key)
+			}
+		default:
+			return "", fmt.Errorf("unknown placeholder {%s} in name template (want algo, seed, w, h, name or n)", key)
+		}
+		rest = rest[open+end+1:]
+	}
+	return sb.String(), nil
+}
+
+// creates a file honouring the overwrite policy
+// the unique policy adds _1, _2... before the extension instead of failing
+func createWithPolicy(name, policy string) (*os.File, string, error) {
+	switch policy {
+	case OverwriteReplace:
+		f, err := os.Create(name)
+		return f, name, err
+	case OverwriteUnique:
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		candidate := name
+		for i := 1; ; i++ {
+			f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if !errors.Is(err, fs.ErrExist) {
+				return f, candidate, err
+			}
+			candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+		}
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, "", fmt.Errorf("%s already exists (use -overwrite replace or unique)", name)
+	}
+	return f, name, err
+}
+
+// writes the image as xpm wherever the output settings point
+// takes: output settings, template variables, image, character codes to keep (nil to generate them)
+// returns: path written ("-" for stdout) and error or nil
+// mutates: filesystem
+func SaveXPM(o Output, vars map[string]string, img *raster.Image, chars []string) (string, error) {
+	if o.Path == "-" {
+		return "-", WriteXPM(os.Stdout, img, chars)
+	}
+	f, name, err := o.Create(vars)
+	if err != nil {
+		return "", err
+	}
+	if err := WriteXPM(f, img, chars); err != nil {
+		f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
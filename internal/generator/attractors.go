@@ -0,0 +1,403 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+func init() {
+	Register(builtin{
+		name:        "attractor",
+		description: "density plot of a chaotic attractor (clifford, de jong, lorenz, ...)",
+		palette:     fixedPalette("#000000", "#111122", "#004488", "#0088CC", "#00FFFF", "#FFFFFF"),
+		params:      attractorParams,
+		animated:    true,
+		mapping:     attractorMapping,
+		field:       runAttractor,
+	})
+}
+
+// one family of attractors
+// maps jump straight to the next point, flows are 3d differential equations
+// integrated in small time steps and projected onto a plane
+type attractorKind struct {
+	coeffs  []string     // coefficient names in use, a subset of a..f in order
+	classic []float64    // known good coefficients, fallback for the random search
+	ranges  [][2]float64 // ranges random coefficients are drawn from (maps only)
+	start   [3]float64   // starting point
+	flow    bool
+	dt      float64 // time step of a flow
+	view    string  // default projection plane of a flow
+	// next point of a map, or the derivative of a flow
+	step func(p [3]float64, k []float64) [3]float64
+}
+
+var attractorKinds = map[string]attractorKind{
+	"clifford": {
+		coeffs: []string{"a", "b", "c", "d"}, classic: []float64{-1.4, 1.6, 1.0, 0.7}, ranges: sameRange(4, -2, 2),
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				math.Sin(k[0]*p[1]) + k[2]*math.Cos(k[0]*p[0]),
+				math.Sin(k[1]*p[0]) + k[3]*math.Cos(k[1]*p[1]),
+			}
+		},
+	},
+	"dejong": {
+		coeffs: []string{"a", "b", "c", "d"}, classic: []float64{1.4, -2.3, 2.4, -2.1}, ranges: sameRange(4, -3, 3),
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				math.Sin(k[0]*p[1]) - math.Cos(k[1]*p[0]),
+				math.Sin(k[2]*p[0]) - math.Cos(k[3]*p[1]),
+			}
+		},
+	},
+	"svensson": {
+		coeffs: []string{"a", "b", "c", "d"}, classic: []float64{1.5, -1.8, 1.6, 0.9}, ranges: sameRange(4, -3, 3),
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				k[3]*math.Sin(k[0]*p[0]) - math.Sin(k[1]*p[1]),
+				k[2]*math.Cos(k[0]*p[0]) + math.Cos(k[1]*p[1]),
+			}
+		},
+	},
+	"bedhead": {
+		coeffs: []string{"a", "b"}, classic: []float64{-0.81, -0.92}, ranges: sameRange(2, -1, 1),
+		start: [3]float64{1, 1},
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				math.Sin(p[0]*p[1]/k[1])*p[1] + math.Cos(k[0]*p[0]-p[1]),
+				p[0] + math.Sin(p[1])/k[1],
+			}
+		},
+	},
+	"hopalong": {
+		coeffs: []string{"a", "b", "c"}, classic: []float64{2.0, 1.0, 0.0}, ranges: sameRange(3, 0, 10),
+		step: func(p [3]float64, k []float64) [3]float64 {
+			sign := 1.0
+			if p[0] < 0 {
+				sign = -1.0
+			}
+			return [3]float64{
+				p[1] - sign*math.Sqrt(math.Abs(k[1]*p[0]-k[2])),
+				k[0] - p[0],
+			}
+		},
+	},
+	"gumowski-mira": {
+		// a is mu, b and c are the alpha and sigma of the damping term
+		coeffs: []string{"a", "b", "c"}, classic: []float64{-0.8, 0.008, 0.05},
+		ranges: [][2]float64{{-1, 1}, {0, 0.02}, {0, 0.1}},
+		start:  [3]float64{0.1, 0.1},
+		step: func(p [3]float64, k []float64) [3]float64 {
+			f := func(x float64) float64 { return k[0]*x + 2*(1-k[0])*x*x/(1+x*x) }
+			x := p[1] + k[1]*p[1]*(1-k[2]*p[1]*p[1]) + f(p[0])
+			return [3]float64{x, -p[0] + f(x)}
+		},
+	},
+	"lorenz": {
+		coeffs: []string{"a", "b", "c"}, classic: []float64{10, 28, 8.0 / 3.0},
+		start: [3]float64{0.1, 0, 0}, flow: true, dt: 0.005, view: "xz",
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				k[0] * (p[1] - p[0]),
+				p[0]*(k[1]-p[2]) - p[1],
+				p[0]*p[1] - k[2]*p[2],
+			}
+		},
+	},
+	"rossler": {
+		coeffs: []string{"a", "b", "c"}, classic: []float64{0.2, 0.2, 5.7},
+		start: [3]float64{0.1, 0, 0}, flow: true, dt: 0.01, view: "xy",
+		step: func(p [3]float64, k []float64) [3]float64 {
+			return [3]float64{
+				-p[1] - p[2],
+				p[0] + k[0]*p[1],
+				k[1] + p[2]*(p[0]-k[2]),
+			}
+		},
+	},
+	"aizawa": {
+		coeffs: []string{"a", "b", "c", "d", "e", "f"}, classic: []float64{0.95, 0.7, 0.6, 3.5, 0.25, 0.1},
+		start: [3]float64{0.1, 0, 0}, flow: true, dt: 0.01, view: "xz",
+		step: func(p [3]float64, k []float64) [3]float64 {
+			x, y, z := p[0], p[1], p[2]
+			return [3]float64{
+				(z-k[1])*x - k[3]*y,
+				k[3]*x + (z-k[1])*y,
+				k[2] + k[0]*z - z*z*z/3 - (x*x+y*y)*(1+k[4]*z) + k[5]*z*x*x*x,
+			}
+		},
+	},
+}
+
+// the same random range for every coefficient
+func sameRange(n int, lo, hi float64) [][2]float64 {
+	r := make([][2]float64, n)
+	for i := range r {
+		r[i] = [2]float64{lo, hi}
+	}
+	return r
+}
+
+func attractorNames() []string {
+	names := make([]string, 0, len(attractorKinds))
+	for name := range attractorKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hit counts span several orders of magnitude, log scaling keeps the faint arms visible
+var attractorMapping = mapping{normalize: "log", gamma: 1}
+
+var attractorParams = []Param{
+	{Name: "type", Kind: ParamChoice, Default: "clifford", Choices: attractorNames(), Usage: "which attractor to plot"},
+	{Name: "a", Kind: ParamFloat, Default: "", Usage: "first coefficient (random for maps, the classic value for flows when unset)"},
+	{Name: "b", Kind: ParamFloat, Default: "", Usage: "second coefficient"},
+	{Name: "c", Kind: ParamFloat, Default: "", Usage: "third coefficient (all but bedhead)"},
+	{Name: "d", Kind: ParamFloat, Default: "", Usage: "fourth coefficient (clifford, dejong, svensson, aizawa)"},
+	{Name: "e", Kind: ParamFloat, Default: "", Usage: "fifth coefficient (aizawa)"},
+	{Name: "f", Kind: ParamFloat, Default: "", Usage: "sixth coefficient (aizawa)"},
+	{Name: "iterations", Kind: ParamInt, Default: "5000000", Usage: "points plotted"},
+	{Name: "view", Kind: ParamChoice, Default: "", Choices: []string{"xy", "xz", "yz"}, Usage: "plane a 3d flow is projected onto (lorenz xz, rossler xy, aizawa xz)"},
+	{Name: "fit", Kind: ParamChoice, Default: "aspect", Choices: []string{"aspect", "stretch"}, Usage: "fit the orbit's bounding box keeping its proportions, or fill the image"},
+}
+
+// iterations that make up one animation step of the attractor
+const attractorChunk = 10000
+
+// points skipped before plotting so the orbit has settled onto the attractor
+const attractorSettle = 1000
+
+// points sampled to find the orbit's bounding box
+const attractorFitSamples = 200000
+
+// plots the density of an attractor orbit
+// maps search for random chaotic coefficients unless they are given,
+// the view is fitted to the bounding box of the orbit
+// takes: config, rng, frame mapping
+// returns: field of hit counts
+func runAttractor(cfg config.Config, rng *rand.Rand, toImage func(*Field) *raster.Image) (*Field, error) {
+	p := paramsFor(cfg, attractorParams)
+	name := p.String("type")
+	kind := attractorKinds[name]
+
+	for _, c := range []string{"a", "b", "c", "d", "e", "f"} {
+		if p.Has(c) && !slices.Contains(kind.coeffs, c) {
+			return nil, fmt.Errorf("attractor: %s has no coefficient %s (it uses %s)", name, c, strings.Join(kind.coeffs, ", "))
+		}
+	}
+	view := kind.view
+	if p.Has("view") {
+		if !kind.flow {
+			return nil, fmt.Errorf("attractor: view only applies to the 3d flows (lorenz, rossler, aizawa)")
+		}
+		view = p.String("view")
+	}
+	iterations := p.Int("iterations")
+	if iterations < 1 {
+		return nil, fmt.Errorf("attractor: iterations must be at least 1")
+	}
+
+	k := attractorCoefficients(kind, p, rng)
+	pt := kind.start
+	if kind.flow {
+		// nudge the start so each seed traces a different orbit
+		for i := range pt {
+			pt[i] += rng.Float64() * 0.1
+		}
+	}
+	next := kind.step
+	if kind.flow {
+		next = func(p [3]float64, k []float64) [3]float64 { return rk4(kind.step, p, k, kind.dt) }
+	}
+	project := func(p [3]float64) (float64, float64) {
+		switch view {
+		case "xz":
+			return p[0], p[2]
+		case "yz":
+			return p[1], p[2]
+		}
+		return p[0], p[1]
+	}
+
+	// first pass: bounding box of the settled orbit
+	samples := min(iterations, attractorFitSamples)
+	xs := make([]float64, 0, samples)
+	ys := make([]float64, 0, samples)
+	q := pt
+	for i := 0; i < attractorSettle+samples; i++ {
+		q = next(q, k)
+		if i < attractorSettle {
+			continue
+		}
+		x, y := project(q)
+		if math.IsInf(x, 0) || math.IsNaN(x) || math.IsInf(y, 0) || math.IsNaN(y) {
+			return nil, fmt.Errorf("attractor: %s orbit escapes to infinity with coefficients %v", name, k)
+		}
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	// a handful of far flung points would shrink everything else to a dot,
+	// so the box leaves out the outermost 0.05% on each side
+	minX, maxX := trimmedRange(xs, 0.0005)
+	minY, maxY := trimmedRange(ys, 0.0005)
+	toScreen := fitView(cfg, minX, maxX, minY, maxY, p.String("fit") == "stretch")
+
+	density := NewField(cfg.Width, cfg.Height)
+	frame := func() *raster.Image { return toImage(density) }
+	emitFrame(cfg, 0, false, frame)
+
+	// second pass: the same orbit again, plotted
+	q = pt
+	for i := 0; i < attractorSettle; i++ {
+		q = next(q, k)
+	}
+	for i := 0; i < iterations; i++ {
+		q = next(q, k)
+		fx, fy := toScreen(project(q))
+		screenX, screenY := int(math.Floor(fx)), int(math.Floor(fy))
+		if cfg.Tileable {
+			// the orbit continues on the other side instead of leaving the view
+			screenX = wrapCoord(screenX, cfg.Width)
+			screenY = wrapCoord(screenY, cfg.Height)
+		}
+		if screenX >= 0 && screenX < cfg.Width && screenY >= 0 && screenY < cfg.Height {
+			density.Values[screenY*cfg.Width+screenX] += 1.0
+		}
+		// a frame step is attractorChunk iterations
+		if (i+1)%attractorChunk == 0 || i+1 == iterations {
+			emitFrame(cfg, (i+1+attractorChunk-1)/attractorChunk, i+1 == iterations, frame)
+		}
+	}
+
+	return density, nil
+}
+
+// picks the coefficients of one run
+// given values always win; flows use the classic values for the rest,
+// maps search random values until the orbit stays finite and spreads out
+// takes: attractor kind, params, rng
+// returns: coefficients in the order of kind.coeffs
+func attractorCoefficients(kind attractorKind, p params, rng *rand.Rand) []float64 {
+	k := make([]float64, len(kind.coeffs))
+	given := 0
+	fill := func(fallback []float64) {
+		for i, c := range kind.coeffs {
+			if p.Has(c) {
+				k[i] = p.Float(c)
+			} else {
+				k[i] = fallback[i]
+			}
+		}
+	}
+	for _, c := range kind.coeffs {
+		if p.Has(c) {
+			given++
+		}
+	}
+	if kind.flow || given == len(kind.coeffs) {
+		fill(kind.classic)
+		return k
+	}
+
+	random := make([]float64, len(kind.coeffs))
+	for attempt := 0; attempt < 100; attempt++ {
+		for i := range random {
+			lo, hi := kind.ranges[i][0], kind.ranges[i][1]
+			random[i] = rng.Float64()*(hi-lo) + lo
+		}
+		fill(random)
+		if chaoticSpread(kind, k) {
+			return k
+		}
+	}
+	fill(kind.classic)
+	return k
+}
+
+// runs a short orbit and checks it neither blows up, collapses onto a
+// point or a thin line, nor settles into a cycle or a smooth loop
+func chaoticSpread(kind attractorKind, k []float64) bool {
+	const nudge = 1e-8
+	q := kind.start
+	// a neighbour orbit, pulled back to nudge apart after every step
+	n := [3]float64{q[0] + nudge, q[1]}
+	lyapunov := 0.0
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	// some maps wander for a long while before settling
+	for i := 0; i < 4000; i++ {
+		q = kind.step(q, k)
+		n = kind.step(n, k)
+		if math.IsInf(q[0], 0) || math.IsNaN(q[0]) || math.IsInf(q[1], 0) || math.IsNaN(q[1]) {
+			return false
+		}
+		dist := math.Hypot(n[0]-q[0], n[1]-q[1])
+		if dist == 0 {
+			// both orbits fell onto the same point
+			return false
+		}
+		n = [3]float64{q[0] + (n[0]-q[0])*nudge/dist, q[1] + (n[1]-q[1])*nudge/dist}
+		if i >= 3000 {
+			minX, maxX = math.Min(minX, q[0]), math.Max(maxX, q[0])
+			minY, maxY = math.Min(minY, q[1]), math.Max(maxY, q[1])
+			lyapunov += math.Log(dist / nudge)
+		}
+	}
+	// neighbouring orbits only drift apart steadily on a chaotic attractor
+	return (maxX-minX) > 0.5 && (maxY-minY) > 0.5 && lyapunov/1000 > 0.005
+}
+
+// range of values with a fraction cut off at both ends
+// takes: values (sorted in place), fraction to drop per side
+// returns: low and high bound
+func trimmedRange(values []float64, cut float64) (float64, float64) {
+	sort.Float64s(values)
+	drop := int(float64(len(values)) * cut)
+	return values[drop], values[len(values)-1-drop]
+}
+
+// one classic runge-kutta step of a flow
+func rk4(deriv func(p [3]float64, k []float64) [3]float64, p [3]float64, k []float64, dt float64) [3]float64 {
+	add := func(a, b [3]float64, s float64) [3]float64 {
+		return [3]float64{a[0] + b[0]*s, a[1] + b[1]*s, a[2] + b[2]*s}
+	}
+	k1 := deriv(p, k)
+	k2 := deriv(add(p, k1, dt/2), k)
+	k3 := deriv(add(p, k2, dt/2), k)
+	k4 := deriv(add(p, k3, dt), k)
+	var out [3]float64
+	for i := range out {
+		out[i] = p[i] + dt/6*(k1[i]+2*k2[i]+2*k3[i]+k4[i])
+	}
+	return out
+}
+
+// maps a bounding box onto the image with a small margin
+// y is flipped so the plot reads the usual way up
+// takes: config, bounding box, whether to fill the image instead of keeping proportions
+// returns: function from plane coords to fractional pixel coords
+func fitView(cfg config.Config, minX, maxX, minY, maxY float64, stretch bool) func(x, y float64) (float64, float64) {
+	const margin = 0.04
+	spanX := math.Max(maxX-minX, 1e-9)
+	spanY := math.Max(maxY-minY, 1e-9)
+	usableW := float64(cfg.Width) * (1 - 2*margin)
+	usableH := float64(cfg.Height) * (1 - 2*margin)
+	scaleX, scaleY := usableW/spanX, usableH/spanY
+	if !stretch {
+		scaleX = math.Min(scaleX, scaleY)
+		scaleY = scaleX
+	}
+	midX, midY := (minX+maxX)/2, (minY+maxY)/2
+	return func(x, y float64) (float64, float64) {
+		return float64(cfg.Width)/2 + (x-midX)*scaleX, float64(cfg.Height)/2 - (y-midY)*scaleY
+	}
+}
@@ -0,0 +1,319 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+func init() {
+	Register(builtin{
+		name:        "perlin",
+		description: "classic perlin gradient noise with fractal octaves (clouds, marble, smoke)",
+		palette:     fixedPalette("#0B1D3A", "#16325C", "#24508A", "#3A73B5", "#6A9ED6", "#A4C8EC", "#DCEBFA", "#FFFFFF"),
+		params:      noiseParams,
+		mapping:     noiseMapping,
+		field: func(cfg config.Config, rng *rand.Rand, _ func(*Field) *raster.Image) (*Field, error) {
+			return runCoherentNoise(cfg, rng, "perlin", noiseParams, perlinAt)
+		},
+	})
+	Register(builtin{
+		name:        "simplex",
+		description: "opensimplex2-style gradient noise on a triangular lattice, fewer grid artifacts than perlin",
+		palette:     fixedPalette("#1B3A6B", "#2E5E9E", "#D9C58B", "#7BA05B", "#4E7D3A", "#6B5A45", "#9C9189", "#FFFFFF"),
+		params:      noiseParams,
+		mapping:     noiseMapping,
+		field: func(cfg config.Config, rng *rand.Rand, _ func(*Field) *raster.Image) (*Field, error) {
+			return runCoherentNoise(cfg, rng, "simplex", noiseParams, simplexAt)
+		},
+	})
+	Register(builtin{
+		name:        "worley",
+		description: "worley/cellular noise from distances to scattered feature points (cells, stone, scales)",
+		palette:     fixedPalette("#10002B", "#240046", "#3C096C", "#5A189A", "#7B2CBF", "#9D4EDD", "#C77DFF", "#E0AAFF"),
+		params:      worleyParams,
+		mapping:     noiseMapping,
+		field: func(cfg config.Config, rng *rand.Rand, _ func(*Field) *raster.Image) (*Field, error) {
+			feature := paramsFor(cfg, worleyParams).String("feature")
+			return runCoherentNoise(cfg, rng, "worley", worleyParams, func(perm *permTable, u, v, cellsX, cellsY float64, wrap bool, off [2]int) float64 {
+				return worleyAt(perm, u, v, cellsX, cellsY, wrap, off, feature)
+			})
+		},
+	})
+}
+
+// the noise is stretched from its lowest to its highest value over the whole palette
+var noiseMapping = mapping{normalize: "linear", gamma: 1}
+
+var noiseParams = []Param{
+	{Name: "frequency", Kind: ParamFloat, Default: "4", Usage: "noise cells across the texture width for the first octave"},
+	{Name: "octaves", Kind: ParamInt, Default: "5", Usage: "number of layers summed together (1-16)"},
+	{Name: "lacunarity", Kind: ParamFloat, Default: "2.0", Usage: "frequency multiplier between octaves"},
+	{Name: "gain", Kind: ParamFloat, Default: "0.5", Usage: "amplitude multiplier between octaves"},
+	{Name: "fractal", Kind: ParamChoice, Default: "fbm", Choices: []string{"fbm", "ridged", "turbulence"}, Usage: "how octaves are combined"},
+}
+
+var worleyParams = append(append([]Param(nil), noiseParams...),
+	Param{Name: "feature", Kind: ParamChoice, Default: "f1", Choices: []string{"f1", "f2", "f2-f1"}, Usage: "distance to the nearest point, second nearest, or their difference (cell edges)"},
+)
+
+// one octave of lattice noise
+// u, v: position as a fraction of the texture (0..1)
+// cellsX, cellsY: wanted number of lattice cells across the texture
+// wrap: snap the cell counts so the noise repeats exactly at u=1 and v=1
+// off: whole-cell offset so octaves don't all start at the same lattice point
+// returns: value in roughly -1..1
+type latticeNoise func(perm *permTable, u, v, cellsX, cellsY float64, wrap bool, off [2]int) float64
+
+// shuffled 0..255, doubled so perm[perm[a]+b] never needs a bounds check
+type permTable [512]int
+
+func newPermTable(rng *rand.Rand) *permTable {
+	var perm permTable
+	for i, v := range rng.Perm(256) {
+		perm[i] = v
+		perm[i+256] = v
+	}
+	return &perm
+}
+
+// hashes a lattice point, callers wrap the coordinates first when tiling
+func (p *permTable) hash(a, b int) int {
+	return p[p[a&255]+(b&255)]
+}
+
+// renders one of the coherent noise generators
+// sums the octaves per pixel
+// takes: config, rng, generator name (for errors), schema, noise function
+// returns: field of summed noise, error for out of range parameters
+func runCoherentNoise(cfg config.Config, rng *rand.Rand, name string, schema []Param, noise latticeNoise) (*Field, error) {
+	p := paramsFor(cfg, schema)
+	frequency := p.Float("frequency")
+	octaves := p.Int("octaves")
+	lacunarity := p.Float("lacunarity")
+	gain := p.Float("gain")
+	fractal := p.String("fractal")
+	switch {
+	case frequency <= 0:
+		return nil, fmt.Errorf("%s: frequency must be above 0", name)
+	case octaves < 1 || octaves > 16:
+		return nil, fmt.Errorf("%s: octaves must be between 1 and 16", name)
+	case lacunarity < 1:
+		return nil, fmt.Errorf("%s: lacunarity must be at least 1", name)
+	case gain <= 0:
+		return nil, fmt.Errorf("%s: gain must be above 0", name)
+	}
+
+	perm := newPermTable(rng)
+	offsets := make([][2]int, octaves)
+	for i := range offsets {
+		offsets[i] = [2]int{rng.Intn(256), rng.Intn(256)}
+	}
+	aspect := float64(cfg.Height) / float64(cfg.Width)
+
+	field := NewField(cfg.Width, cfg.Height)
+	parallelRows(cfg.Height, cfg.Workers, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			v := float64(y) / float64(cfg.Height)
+			for x := 0; x < cfg.Width; x++ {
+				u := float64(x) / float64(cfg.Width)
+				sum, norm, amp := 0.0, 0.0, 1.0
+				cells := frequency
+				for o := 0; o < octaves; o++ {
+					n := noise(perm, u, v, cells, cells*aspect, cfg.Tileable, offsets[o])
+					switch fractal {
+					case "ridged":
+						// sharp crests where the noise crosses zero
+						n = 1 - math.Abs(n)
+						n *= n
+					case "turbulence":
+						n = math.Abs(n)
+					default:
+						n = 0.5 + 0.5*n
+					}
+					sum += amp * n
+					norm += amp
+					amp *= gain
+					cells *= lacunarity
+				}
+				field.Set(x, y, sum/norm)
+			}
+		}
+	})
+
+	return field, nil
+}
+
+// whole number of cells for a tiling texture, at least one
+func tileCells(cells float64) int {
+	return max(1, int(math.Round(cells)))
+}
+
+// 8 unit gradients, 45 degrees apart
+var perlinGradients = func() [8][2]float64 {
+	var g [8][2]float64
+	for i := range g {
+		a := float64(i) * math.Pi / 4
+		g[i] = [2]float64{math.Cos(a), math.Sin(a)}
+	}
+	return g
+}()
+
+// improved perlin noise on a square lattice, periodic when wrap is set
+func perlinAt(perm *permTable, u, v, cellsX, cellsY float64, wrap bool, off [2]int) float64 {
+	px, py := 0, 0
+	if wrap {
+		px, py = tileCells(cellsX), tileCells(cellsY)
+		cellsX, cellsY = float64(px), float64(py)
+	}
+	x := u*cellsX + float64(off[0])
+	y := v*cellsY + float64(off[1])
+
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+
+	corner := func(cx, cy int, dx, dy float64) float64 {
+		if wrap {
+			cx, cy = wrapCoord(cx, px), wrapCoord(cy, py)
+		}
+		g := perlinGradients[perm.hash(cx, cy)&7]
+		return g[0]*dx + g[1]*dy
+	}
+	n00 := corner(ix, iy, fx, fy)
+	n10 := corner(ix+1, iy, fx-1, fy)
+	n01 := corner(ix, iy+1, fx, fy-1)
+	n11 := corner(ix+1, iy+1, fx-1, fy-1)
+
+	sx, sy := fade(fx), fade(fy)
+	top := n00 + sx*(n10-n00)
+	bottom := n01 + sx*(n11-n01)
+	// unit gradients peak at sqrt(1/2)
+	return (top + sy*(bottom-top)) * math.Sqrt2
+}
+
+// perlin's quintic smoothstep, 6t^5 - 15t^4 + 10t^3
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// opensimplex2 lattice constants
+const (
+	simplexSkew    = 0.366025403784439    // (sqrt(3)-1)/2
+	simplexUnskew  = -0.21132486540518713 // (1/sqrt(3)-1)/2
+	simplexRadius2 = 0.5                  // squared kernel radius
+	simplexScale   = 99.2                 // brings the output to about -1..1
+)
+
+// 24 unit gradients, 15 degrees apart
+var simplexGradients = func() [24][2]float64 {
+	var g [24][2]float64
+	for i := range g {
+		a := (float64(i) + 0.5) * math.Pi / 12
+		g[i] = [2]float64{math.Cos(a), math.Sin(a)}
+	}
+	return g
+}()
+
+// opensimplex2-style noise on the triangular lattice
+//
+// the lattice repeats along the diagonals, so the texture axes are turned
+// 45 degrees onto them: one step along x is the lattice vector (1,1) and
+// one step along y is (1,-1), which is sqrt(3) times longer. a texture
+// spanning n and m of those steps then tiles, and lattice points are
+// wrapped by reducing i+j modulo 2n and i-j modulo 2m
+func simplexAt(perm *permTable, u, v, cellsX, cellsY float64, wrap bool, off [2]int) float64 {
+	// y steps are sqrt(3) longer, use fewer of them for round features
+	stepsX, stepsY := cellsX, cellsY/math.Sqrt(3)
+	n, m := 0, 0
+	if wrap {
+		n, m = tileCells(stepsX), tileCells(stepsY)
+		stepsX, stepsY = float64(n), float64(m)
+	}
+	X := u*stepsX + float64(off[0])
+	Y := v*stepsY + float64(off[1])
+
+	// rotate onto the lattice diagonals
+	x := X/math.Sqrt(3) + Y
+	y := X/math.Sqrt(3) - Y
+
+	// skew into lattice space
+	s := simplexSkew * (x + y)
+	xs, ys := x+s, y+s
+	xsb, ysb := math.Floor(xs), math.Floor(ys)
+	i, j := int(xsb), int(ysb)
+	xi, yi := xs-xsb, ys-ysb
+
+	t := (xi + yi) * simplexUnskew
+	dx0, dy0 := xi+t, yi+t
+
+	vertex := func(vi, vj int, dx, dy float64) float64 {
+		a := simplexRadius2 - dx*dx - dy*dy
+		if a <= 0 {
+			return 0
+		}
+		sum, diff := vi+vj, vi-vj
+		if wrap {
+			sum, diff = wrapCoord(sum, 2*n), wrapCoord(diff, 2*m)
+		}
+		g := simplexGradients[perm.hash(sum, diff)%24]
+		a *= a
+		return a * a * (g[0]*dx + g[1]*dy)
+	}
+
+	value := vertex(i, j, dx0, dy0)
+	value += vertex(i+1, j+1, dx0-(1+2*simplexUnskew), dy0-(1+2*simplexUnskew))
+	if dy0 > dx0 {
+		value += vertex(i, j+1, dx0-simplexUnskew, dy0-(1+simplexUnskew))
+	} else {
+		value += vertex(i+1, j, dx0-(1+simplexUnskew), dy0-simplexUnskew)
+	}
+	return value * simplexScale
+}
+
+// worley noise with one jittered feature point per square cell
+// feature picks the nearest distance (f1), second nearest (f2) or f2-f1
+func worleyAt(perm *permTable, u, v, cellsX, cellsY float64, wrap bool, off [2]int, feature string) float64 {
+	px, py := 0, 0
+	if wrap {
+		px, py = tileCells(cellsX), tileCells(cellsY)
+		cellsX, cellsY = float64(px), float64(py)
+	}
+	x := u*cellsX + float64(off[0])
+	y := v*cellsY + float64(off[1])
+	cx, cy := int(math.Floor(x)), int(math.Floor(y))
+
+	f1, f2 := math.Inf(1), math.Inf(1)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			gx, gy := cx+dx, cy+dy
+			hx, hy := gx, gy
+			if wrap {
+				hx, hy = wrapCoord(gx, px), wrapCoord(gy, py)
+			}
+			h := perm.hash(hx, hy)
+			fx := float64(gx) + float64(perm[h])/256
+			fy := float64(gy) + float64(perm[h+1])/256
+			d := math.Hypot(fx-x, fy-y)
+			if d < f1 {
+				f1, f2 = d, f1
+			} else if d < f2 {
+				f2 = d
+			}
+		}
+	}
+
+	var val float64
+	switch feature {
+	case "f2":
+		val = f2 / 1.5
+	case "f2-f1":
+		val = f2 - f1
+	default:
+		val = f1
+	}
+	return 2*math.Min(val, 1) - 1
+}
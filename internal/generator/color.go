@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"xpm-gen/internal/colors"
+)
+
+// just a helper to convert hsv values to a hex string
+// takes: hue (0-360), saturation and value (0-100)
+// returns: "#RRGGBB"
+func hsvToHex(h, s, v float64) string {
+	s /= 100
+	v /= 100
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60.0, 2)-1))
+	m := v - c
+	var r, g, b float64
+	if 0 <= h && h < 60 {
+		r, g, b = c, x, 0
+	} else if 60 <= h && h < 120 {
+		r, g, b = x, c, 0
+	} else if 120 <= h && h < 180 {
+		r, g, b = 0, c, x
+	} else if 180 <= h && h < 240 {
+		r, g, b = 0, x, c
+	} else if 240 <= h && h < 300 {
+		r, g, b = x, 0, c
+	} else {
+		r, g, b = c, 0, x
+	}
+	return fmt.Sprintf("#%02X%02X%02X", int((r+m)*255), int((g+m)*255), int((b+m)*255))
+}
+
+// blends each palette entry into the next, the last one back into the first
+// takes: palette, colors per entry (including the entry itself)
+// returns: len(stops) * steps colors, error for unparseable entries
+func interpolatePalette(stops []string, steps int) ([]string, error) {
+	parsed := make([]color.NRGBA, len(stops))
+	for i, s := range stops {
+		c, err := colors.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("palette entry %d: %w", i, err)
+		}
+		parsed[i] = c
+	}
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+
+	out := make([]string, 0, len(stops)*steps)
+	for i, a := range parsed {
+		b := parsed[(i+1)%len(parsed)]
+		for k := 0; k < steps; k++ {
+			t := float64(k) / float64(steps)
+			out = append(out, colors.Hex(color.NRGBA{
+				R: lerp(a.R, b.R, t),
+				G: lerp(a.G, b.G, t),
+				B: lerp(a.B, b.B, t),
+				A: lerp(a.A, b.A, t),
+			}))
+		}
+	}
+	return out, nil
+}
+
+// turns the hue of a color, keeping saturation and value
+// takes: color in any form colors.Parse accepts, degrees to turn
+// returns: "#RRGGBB" or an error for an unparseable color
+func rotateHue(c string, degrees float64) (string, error) {
+	rgb, err := colors.Parse(c)
+	if err != nil {
+		return "", err
+	}
+	r, g, b := float64(rgb.R)/255, float64(rgb.G)/255, float64(rgb.B)/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	chroma := hi - lo
+	var h float64
+	switch {
+	case chroma == 0:
+		h = 0
+	case hi == r:
+		h = 60 * math.Mod((g-b)/chroma, 6)
+	case hi == g:
+		h = 60 * ((b-r)/chroma + 2)
+	default:
+		h = 60 * ((r-g)/chroma + 4)
+	}
+	s := 0.0
+	if hi > 0 {
+		s = chroma / hi
+	}
+	h = math.Mod(h+degrees+720, 360)
+	return hsvToHex(h, s*100, hi*100), nil
+}
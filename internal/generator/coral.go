@@ -1,15 +1,137 @@
 package generator
 
 import (
+	"fmt"
 	"math/rand"
+	"sort"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 	"github.com/schollz/progressbar/v3"
 )
 
+func init() {
+	Register(builtin{
+		name:        "coral",
+		description: "gray-scott reaction diffusion grown into coral-like branches",
+		// electric blue / cyan / magenta gradient
+		palette:  namedPalette("coral"),
+		params:   coralParams,
+		animated: true,
+		mapping:  coralMapping,
+		field:    runCoral,
+	})
+}
+
+// named feed/kill pairs from pearson's classification of gray-scott patterns
+// tuned for the diffusion rates below (diffa=1.0, diffb=0.5)
+var coralPresets = map[string][2]float64{
+	"coral":     {0.0545, 0.062},
+	"mitosis":   {0.0367, 0.0649},
+	"spots":     {0.030, 0.062},
+	"worms":     {0.078, 0.061},
+	"labyrinth": {0.029, 0.057},
+	"holes":     {0.039, 0.058},
+}
+
+// b usually stays between 0 and 0.4, the threshold hides the faintest haze
+var coralMapping = mapping{normalize: "fixed", lo: 0, hi: 0.4, gamma: 1, threshold: 0.05}
+
+var coralParams = []Param{
+	{Name: "preset", Kind: ParamChoice, Default: "coral", Choices: presetNames(), Usage: "named feed/kill pair"},
+	{Name: "feed", Kind: ParamFloat, Default: "0.0545", Usage: "feed rate of chemical a, overrides the preset"},
+	{Name: "kill", Kind: ParamFloat, Default: "0.062", Usage: "kill rate of chemical b, overrides the preset"},
+	{Name: "diffa", Kind: ParamFloat, Default: "1.0", Usage: "diffusion rate of chemical a"},
+	{Name: "diffb", Kind: ParamFloat, Default: "0.5", Usage: "diffusion rate of chemical b"},
+	{Name: "steps", Kind: ParamInt, Default: "1000", Usage: "simulation steps"},
+	{Name: "seeding", Kind: ParamChoice, Default: "noise", Choices: []string{"noise", "center", "circles", "mask"}, Usage: "where chemical b starts out"},
+	{Name: "density", Kind: ParamFloat, Default: "0.10", Usage: "fraction of seeded pixels for noise seeding"},
+	{Name: "circles", Kind: ParamInt, Default: "8", Usage: "number of blobs for circles seeding"},
+	{Name: "mask", Kind: ParamString, Default: "", Usage: "xpm whose non-background pixels seed chemical b (implies seeding=mask)"},
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(coralPresets))
+	for name := range coralPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fills chemical b according to the seeding mode
+// takes: grid of b, params, rng
+// returns: error if the mask can't be used
+// mutates: gridB
+func seedCoral(gridB [][]float64, p params, rng *rand.Rand) error {
+	height, width := len(gridB), len(gridB[0])
+	mode := p.String("seeding")
+	if p.Has("mask") && !p.Has("seeding") {
+		mode = "mask"
+	}
+
+	switch mode {
+	case "noise":
+		// heavy noise seeding to ensure it doesn't die out
+		density := p.Float("density")
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if rng.Float64() < density {
+					gridB[y][x] = 1.0
+				}
+			}
+		}
+
+	case "center":
+		// a single square in the middle, the classic textbook start
+		half := max(min(width, height)/10, 1)
+		for y := height/2 - half; y < height/2+half; y++ {
+			for x := width/2 - half; x < width/2+half; x++ {
+				gridB[(y+height)%height][(x+width)%width] = 1.0
+			}
+		}
+
+	case "circles":
+		size := float64(min(width, height))
+		for i := 0; i < p.Int("circles"); i++ {
+			cx := rng.Float64() * float64(width)
+			cy := rng.Float64() * float64(height)
+			r := size * (0.03 + rng.Float64()*0.05)
+			for y := int(cy - r); y <= int(cy+r); y++ {
+				for x := int(cx - r); x <= int(cx+r); x++ {
+					dx, dy := float64(x)-cx, float64(y)-cy
+					if dx*dx+dy*dy <= r*r {
+						// wrap like the simulation does
+						gridB[(y%height+height)%height][(x%width+width)%width] = 1.0
+					}
+				}
+			}
+		}
+
+	case "mask":
+		path := p.String("mask")
+		if path == "" {
+			return fmt.Errorf("coral: seeding=mask needs mask=<file.xpm>")
+		}
+		mask, err := maskFromXPM(path, width, height)
+		if err != nil {
+			return fmt.Errorf("coral: reading mask: %w", err)
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if mask[y][x] {
+					gridB[y][x] = 1.0
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // gray-scott reaction diffusion simulation
 // generates biological patterns like coral, fingerprints, and spots
-func runCoral(cfg config.Config) [][]int {
+func runCoral(cfg config.Config, rng *rand.Rand, toImage func(*Field) *raster.Image) (*Field, error) {
 	width, height := cfg.Width, cfg.Height
+	p := paramsFor(cfg, coralParams)
 	
 	// grids for chemicals A and B
 	// a = feed, b = kill
@@ -26,106 +148,91 @@ func runCoral(cfg config.Config) [][]int {
 		
 		for x := 0; x < width; x++ {
 			gridA[y][x] = 1.0 // fill world with 'feed'
-			// heavy noise seeding to ensure it doesn't die out
-			if rand.Float64() < 0.10 { 
-				gridB[y][x] = 1.0
-			} else {
-				gridB[y][x] = 0.0
-			}
 		}
 	}
+	if err := seedCoral(gridB, p, rng); err != nil {
+		return nil, err
+	}
 	
-	// reverted to "Standard Coral" parameters which are very robust
-	// these are guaranteed to grow and fill the screen
-	feed := 0.0545
-	k := 0.062
-	diffA := 1.0
-	diffB := 0.5
+	// "Standard Coral" is the default since it's very robust
+	// it's guaranteed to grow and fill the screen
+	preset := coralPresets[p.String("preset")]
+	feed := preset[0]
+	k := preset[1]
+	if p.Has("feed") { feed = p.Float("feed") }
+	if p.Has("kill") { k = p.Float("kill") }
+	diffA := p.Float("diffa")
+	diffB := p.Float("diffb")
 	
-	steps := 1000
+	steps := p.Int("steps")
 	bar := progressbar.Default(int64(steps), "growing coral")
+	frame := func() *raster.Image { return toImage(coralField(gridB)) }
+	emitFrame(cfg, 0, steps == 0, frame)
 
 	for step := 0; step < steps; step++ {
 		bar.Add(1)
 		
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				a := gridA[y][x]
-				b := gridB[y][x]
+		// each band reads the old grids and writes only its own rows of the new ones
+		parallelRows(height, cfg.Workers, func(y0, y1 int) {
+			for y := y0; y < y1; y++ {
+				for x := 0; x < width; x++ {
+					a := gridA[y][x]
+					b := gridB[y][x]
 				
-				// laplacian (diffusion) using 3x3 convolution
-				lapA := 0.0
-				lapB := 0.0
+					// laplacian (diffusion) using 3x3 convolution
+					lapA := 0.0
+					lapB := 0.0
 				
-				for dy := -1; dy <= 1; dy++ {
-					for dx := -1; dx <= 1; dx++ {
-						ny := (y + dy + height) % height
-						nx := (x + dx + width) % width
+					for dy := -1; dy <= 1; dy++ {
+						for dx := -1; dx <= 1; dx++ {
+							ny := (y + dy + height) % height
+							nx := (x + dx + width) % width
 						
-						weight := 0.0
-						if dx == 0 && dy == 0 {
-							weight = -1.0
-						} else if dx == 0 || dy == 0 {
-							weight = 0.2
-						} else {
-							weight = 0.05
-						}
+							weight := 0.0
+							if dx == 0 && dy == 0 {
+								weight = -1.0
+							} else if dx == 0 || dy == 0 {
+								weight = 0.2
+							} else {
+								weight = 0.05
+							}
 						
-						lapA += gridA[ny][nx] * weight
-						lapB += gridB[ny][nx] * weight
+							lapA += gridA[ny][nx] * weight
+							lapB += gridB[ny][nx] * weight
+						}
 					}
-				}
 				
-				// reaction-diffusion formula
-				abb := a * b * b
+					// reaction-diffusion formula
+					abb := a * b * b
 				
-				newA := a + (diffA * lapA) - abb + (feed * (1.0 - a))
-				newB := b + (diffB * lapB) + abb - ((k + feed) * b)
+					newA := a + (diffA * lapA) - abb + (feed * (1.0 - a))
+					newB := b + (diffB * lapB) + abb - ((k + feed) * b)
 				
-				// clamp
-				if newA < 0 { newA = 0 }
-				if newA > 1 { newA = 1 }
-				if newB < 0 { newB = 0 }
-				if newB > 1 { newB = 1 }
+					// clamp
+					if newA < 0 { newA = 0 }
+					if newA > 1 { newA = 1 }
+					if newB < 0 { newB = 0 }
+					if newB > 1 { newB = 1 }
 				
-				nextA[y][x] = newA
-				nextB[y][x] = newB
+					nextA[y][x] = newA
+					nextB[y][x] = newB
+				}
 			}
-		}
+		})
 		
-		for y := 0; y < height; y++ {
-			copy(gridA[y], nextA[y])
-			copy(gridB[y], nextB[y])
-		}
+		gridA, nextA = nextA, gridA
+		gridB, nextB = nextB, gridB
+		emitFrame(cfg, step+1, step+1 == steps, frame)
 	}
 
-	// convert (visualize B concentration)
-	outGrid := make([][]int, height)
-	numColors := len(cfg.Colors)
-	
-	for y := 0; y < height; y++ {
-		outGrid[y] = make([]int, width)
-		for x := 0; x < width; x++ {
-			val := gridB[y][x]
-			
-			// lower visual threshold so we can see the pattern even if it's faint
-			// val is usually 0.0 to 0.4
-			
-			// normalize typical range
-			val = val * 2.5 
-			
-			if val < 0.05 { 
-				val = 0 
-			}
-			
-			if val > 1.0 { val = 1.0 }
-			
-			idx := int(val * float64(numColors))
-			if idx >= numColors { idx = numColors - 1 }
-			if idx < 0 { idx = 0 }
-			outGrid[y][x] = idx
-		}
-	}
+	return coralField(gridB), nil
+}
 
-	return outGrid
+// copies the b concentration into a field
+func coralField(gridB [][]float64) *Field {
+	f := NewField(len(gridB[0]), len(gridB))
+	for y, row := range gridB {
+		copy(f.Values[y*f.Width:], row)
+	}
+	return f
 }
@@ -4,22 +4,48 @@ import (
 	"math"
 	"math/rand"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 )
 
+func init() {
+	Register(builtin{
+		name:        "cute",
+		description: "symmetric metaball blob with big baby eyes",
+		palette:     cutePalette,
+		minColors:   3, // background, body and eyes
+		generate:    runCuteGenerator,
+	})
+}
+
+// procedural color harmony (hsv)
+// transparent background, pastel body and complementary eyes
+func cutePalette(rng *rand.Rand) []string {
+	baseHue := float64(rng.Intn(360))
+
+	// body: base hue, low sat (50), high val (95) -> gives us that pastel look
+	bodyColor := hsvToHex(baseHue, 50, 95)
+
+	// eyes: complementary hue (+180), high sat (80), med val (50) -> high contrast to pop out
+	eyeHue := math.Mod(baseHue+180, 360)
+	eyeColor := hsvToHex(eyeHue, 80, 50)
+
+	// background: transparent
+	bgColor := "None"
+
+	return []string{bgColor, bodyColor, eyeColor}
+}
+
 type Point struct {
 	x, y, r float64
 }
 
 // doing the metaballs thing for blobs and neoteny for the cute faces.
-func runCuteGenerator(cfg config.Config) [][]int {
-	grid := make([][]int, cfg.Height)
-	for i := range grid {
-		grid[i] = make([]int, cfg.Width)
-	}
+func runCuteGenerator(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+	grid := raster.New(cfg.Width, cfg.Height, cfg.Colors)
 
 	// 1. spawn some metaballs (the hearts of the creature)
 	// random locations, but mirrored across the y-axis so it looks symmetric
-	numHearts := 3 + rand.Intn(3) // 3 to 5
+	numHearts := 3 + rng.Intn(3) // 3 to 5
 	balls := []Point{}
 
 	centerX := float64(cfg.Width) / 2.0
@@ -30,14 +56,14 @@ func runCuteGenerator(cfg config.Config) [][]int {
 
 	for i := 0; i < numHearts; i++ {
 		// spawn on the left (or center)
-		px := (centerX - spawnWidth/2) + rand.Float64()*spawnWidth
-		py := spawnOffsetY + rand.Float64()*spawnHeight
+		px := (centerX - spawnWidth/2) + rng.Float64()*spawnWidth
+		py := spawnOffsetY + rng.Float64()*spawnHeight
 		
 		// random size
 		// scale it based on the image size, like 5-15% of the width
 		minR := float64(cfg.Width) * 0.05
 		maxR := float64(cfg.Width) * 0.15
-		r := minR + rand.Float64()*(maxR-minR)
+		r := minR + rng.Float64()*(maxR-minR)
 
 		balls = append(balls, Point{px, py, r})
 		
@@ -59,7 +85,11 @@ func runCuteGenerator(cfg config.Config) [][]int {
 			fx, fy := float64(x), float64(y)
 			
 			for _, b := range balls {
-				distSq := (fx-b.x)*(fx-b.x) + (fy-b.y)*(fy-b.y)
+				dx, dy := fx-b.x, fy-b.y
+				if cfg.Tileable {
+					dx, dy = wrapDelta(dx, float64(cfg.Width)), wrapDelta(dy, float64(cfg.Height))
+				}
+				distSq := dx*dx + dy*dy
 				if distSq < 1.0 {
 					distSq = 1.0 // avoid dividing by zero
 				}
@@ -68,20 +98,20 @@ func runCuteGenerator(cfg config.Config) [][]int {
 
 			// threshold
 			if influence > 1.2 { // magic number to tune how blobby it is
-				grid[y][x] = 1 // body color (index 1)
+				grid.Set(x, y, 1) // body color (index 1)
 				
 				// track bounds so we know where the head is
 				if y < minY { minY = y }
 				if y > maxY { maxY = y }
 			} else {
-				grid[y][x] = 0 // background (index 0)
+				grid.Set(x, y, 0) // background (index 0)
 			}
 		}
 	}
 
 	// if nothing got drawn, just bail out (unlikely though)
 	if maxY == -1 {
-		return grid
+		return grid, nil
 	}
 
 	// 3. neoteny ratio (making the face look cute)
@@ -107,21 +137,24 @@ func runCuteGenerator(cfg config.Config) [][]int {
 	drawEye(grid, lx, eyeY, eyeRadius, cfg)
 	drawEye(grid, rx, eyeY, eyeRadius, cfg)
 
-	return grid
+	return grid, nil
 }
 
-func drawEye(grid [][]int, cx, cy, r int, cfg config.Config) {
+func drawEye(grid *raster.Image, cx, cy, r int, cfg config.Config) {
 	// simple filled circle
 	// using color index 2 for the eyes
 	colorIdx := 2
 	
 	for y := cy - r; y <= cy + r; y++ {
 		for x := cx - r; x <= cx + r; x++ {
-			if x >= 0 && x < cfg.Width && y >= 0 && y < cfg.Height {
-				dist := math.Sqrt(float64((x-cx)*(x-cx) + (y-cy)*(y-cy)))
-				if dist <= float64(r) {
-					grid[y][x] = colorIdx
-				}
+			dist := math.Sqrt(float64((x-cx)*(x-cx) + (y-cy)*(y-cy)))
+			if dist > float64(r) {
+				continue
+			}
+			if cfg.Tileable {
+				grid.Set(wrapCoord(x, cfg.Width), wrapCoord(y, cfg.Height), colorIdx)
+			} else if x >= 0 && x < cfg.Width && y >= 0 && y < cfg.Height {
+				grid.Set(x, y, colorIdx)
 			}
 		}
 	}
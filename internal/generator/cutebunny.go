@@ -3,33 +3,52 @@ package generator
 import (
 	"math/rand"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 )
 
-// basically the cute generator but with guaranteed long ears
-func runCuteBunnyGenerator(cfg config.Config) [][]int {
-	grid := make([][]int, cfg.Height)
-	for i := range grid {
-		grid[i] = make([]int, cfg.Width)
+func init() {
+	Register(builtin{
+		name:        "cutebunny",
+		description: "the cute blob with guaranteed long bunny ears",
+		palette:     cuteBunnyPalette,
+		minColors:   3, // background, body and eyes
+		generate:    runCuteBunnyGenerator,
+	})
+}
+
+// soft whites, pinks, browns
+func cuteBunnyPalette(rng *rand.Rand) []string {
+	palettes := [][]string{
+		{"None", "#FFFFFF", "#FF69B4"}, // white bunny, pink eyes
+		{"None", "#FFC0CB", "#000000"}, // pink bunny, black eyes
+		{"None", "#D2B48C", "#5C4033"}, // brown bunny, dark eyes
+		{"None", "#E6E6FA", "#4B0082"}, // lavender bunny, indigo eyes
 	}
+	return palettes[rng.Intn(len(palettes))]
+}
+
+// basically the cute generator but with guaranteed long ears
+func runCuteBunnyGenerator(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+	grid := raster.New(cfg.Width, cfg.Height, cfg.Colors)
 
 	balls := []Point{}
 	centerX := float64(cfg.Width) / 2.0
 	
 	// 1. the body (just like cute.go)
 	// mostly concentrated in bottom half
-	numBodyParts := 3 + rand.Intn(3)
+	numBodyParts := 3 + rng.Intn(3)
 	
 	spawnWidth := float64(cfg.Width) * 0.4
 	spawnHeight := float64(cfg.Height) * 0.4
 	spawnOffsetY := float64(cfg.Height) * 0.4 // lower down
 
 	for i := 0; i < numBodyParts; i++ {
-		px := (centerX - spawnWidth/2) + rand.Float64()*spawnWidth
-		py := spawnOffsetY + rand.Float64()*spawnHeight
+		px := (centerX - spawnWidth/2) + rng.Float64()*spawnWidth
+		py := spawnOffsetY + rng.Float64()*spawnHeight
 		
 		minR := float64(cfg.Width) * 0.08
 		maxR := float64(cfg.Width) * 0.18
-		r := minR + rand.Float64()*(maxR-minR)
+		r := minR + rng.Float64()*(maxR-minR)
 
 		balls = append(balls, Point{px, py, r})
 		mx := centerX + (centerX - px)
@@ -38,7 +57,7 @@ func runCuteBunnyGenerator(cfg config.Config) [][]int {
 
 	// 2. the ears (the important part)
 	// we stack circles to make them long
-	earLen := 3 + rand.Intn(3) // how many balls tall the ear is
+	earLen := 3 + rng.Intn(3) // how many balls tall the ear is
 	earBaseX := centerX - (float64(cfg.Width) * 0.15) // offset from center
 	earBaseY := spawnOffsetY // start where body starts
 	earRadius := float64(cfg.Width) * 0.06
@@ -66,22 +85,26 @@ func runCuteBunnyGenerator(cfg config.Config) [][]int {
 			fx, fy := float64(x), float64(y)
 			
 			for _, b := range balls {
-				distSq := (fx-b.x)*(fx-b.x) + (fy-b.y)*(fy-b.y)
+				dx, dy := fx-b.x, fy-b.y
+				if cfg.Tileable {
+					dx, dy = wrapDelta(dx, float64(cfg.Width)), wrapDelta(dy, float64(cfg.Height))
+				}
+				distSq := dx*dx + dy*dy
 				if distSq < 1.0 { distSq = 1.0 }
 				influence += (b.r * b.r) / distSq
 			}
 
 			if influence > 1.2 {
-				grid[y][x] = 1
+				grid.Set(x, y, 1)
 				if y < minY { minY = y }
 				if y > maxY { maxY = y }
 			} else {
-				grid[y][x] = 0
+				grid.Set(x, y, 0)
 			}
 		}
 	}
 
-	if maxY == -1 { return grid }
+	if maxY == -1 { return grid, nil }
 
 	// 4. face logic
 	// bunny eyes need to be wider apart maybe?
@@ -103,5 +126,5 @@ func runCuteBunnyGenerator(cfg config.Config) [][]int {
 	drawEye(grid, lx, eyeY, eyeRadius, cfg)
 	drawEye(grid, rx, eyeY, eyeRadius, cfg)
 
-	return grid
+	return grid, nil
 }
@@ -1,97 +1,77 @@
 package generator
 
 import (
-	"math"
+	"fmt"
 	"math/rand"
+	"slices"
+
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 )
 
-// allocates and populates the color grid based on configuration
-// routes execution to specific algorithms or simulations
+// looks up the configured algorithm and renders it
+// every random decision is drawn from a rng seeded with cfg.Seed
 // takes: cfg (configuration struct)
-// returns: 2d array of color indices
-func GenerateGrid(cfg config.Config) [][]int {
-	grid := make([][]int, cfg.Height)
-	for y := 0; y < cfg.Height; y++ {
-		grid[y] = make([]int, cfg.Width)
-	}
-
-	// pre-calculate randomizers
-	randX := rand.Intn(1000)
-	randY := rand.Intn(1000)
-	randColorOffset := rand.Intn(len(cfg.Colors))
-	juliaCx := (rand.Float64() * 2.0) - 1.0
-	juliaCy := (rand.Float64() * 2.0) - 1.0
-	mandelZoom := 0.5 + rand.Float64()
-
-	// stateful simulations
-	if cfg.Algorithm == "melting" {
-		return runMeltingSimulation(cfg)
-	}
-	if cfg.Algorithm == "creature" {
-		return runCreatureGenerator(cfg)
+// returns: image indexing cfg.Colors, error for unknown algorithms or bad params
+func GenerateImage(cfg config.Config) (*raster.Image, error) {
+	g, ok := Lookup(cfg.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm '%s'", cfg.Algorithm)
 	}
-	if cfg.Algorithm == "cute" {
-		return runCuteGenerator(cfg)
+	if err := CheckParams(g, cfg.Params); err != nil {
+		return nil, err
 	}
-	if cfg.Algorithm == "cutebunny" {
-		return runCuteBunnyGenerator(cfg)
+	if len(cfg.Colors) < g.MinColors() {
+		return nil, fmt.Errorf("%s needs at least %d colors, got %d", g.Name(), g.MinColors(), len(cfg.Colors))
 	}
-	if cfg.Algorithm == "physarum" {
-		return runPhysarum(cfg)
+	if len(cfg.Colors) > raster.MaxColors {
+		return nil, fmt.Errorf("at most %d colors fit in an image, got %d", raster.MaxColors, len(cfg.Colors))
 	}
-	if cfg.Algorithm == "coral" {
-		return runCoral(cfg)
-	}
-	if cfg.Algorithm == "attractor" {
-		return runAttractor(cfg)
+	if cfg.Dither != "" && !slices.Contains(DitherModes, cfg.Dither) {
+		return nil, fmt.Errorf("unknown dither mode '%s'", cfg.Dither)
 	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	return g.Generate(cfg, rng)
+}
 
-	// stateless pixel-by-pixel generation
+// allocates the image and fills it pixel by pixel
+// shared by all the stateless generators
+// takes: cfg, per-pixel function returning a color index
+// returns: cfg.Width x cfg.Height image indexing cfg.Colors
+func fillImage(cfg config.Config, pixel func(x, y int) int) *raster.Image {
+	img := raster.New(cfg.Width, cfg.Height, cfg.Colors)
 	for y := 0; y < cfg.Height; y++ {
-		for x := 0; x < cfg.Width; x++ {
-			var colorIdx int
-			switch cfg.Algorithm {
-			case "noise":
-				colorIdx = noise(cfg)
-			case "xor":
-				colorIdx = xorPattern(x, y, randX, randY, cfg)
-			case "circles":
-				colorIdx = circles(x, y, randX, randY, randColorOffset, cfg)
-			case "pastel":
-				colorIdx = pastel(x, y, randX, randY, cfg)
-			case "mandelbrot":
-				colorIdx = mandelbrot(x, y, cfg, mandelZoom, randColorOffset)
-			case "julia":
-				colorIdx = julia(x, y, cfg, juliaCx, juliaCy, randColorOffset)
-			default:
-				colorIdx = 0
-			}
-			grid[y][x] = colorIdx
+		row := img.Row(y)
+		for x := range row {
+			row[x] = uint16(pixel(x, y))
 		}
 	}
-	return grid
+	return img
 }
 
-// GenerateFromExpression generates a grid using a custom Expression
-func GenerateFromExpression(cfg config.Config, expr Expression) [][]int {
-	grid := make([][]int, cfg.Height)
-	w, h := float64(cfg.Width), float64(cfg.Height)
-	
+// allocates a field and fills it pixel by pixel
+// shared by the stateless continuous generators
+// takes: cfg, per-pixel function returning a raw value
+// returns: cfg.Width x cfg.Height field
+func fillField(cfg config.Config, pixel func(x, y int) float64) *Field {
+	f := NewField(cfg.Width, cfg.Height)
 	for y := 0; y < cfg.Height; y++ {
-		grid[y] = make([]int, cfg.Width)
 		for x := 0; x < cfg.Width; x++ {
-			// Evaluate expression
-			val := expr.Eval(float64(x), float64(y), w, h)
-			
-			// Map result to color index
-			// We use Mod and Abs to ensure it stays within bounds
-			// Multiply by length to use the full range
-			idx := int(math.Abs(val) * float64(len(cfg.Colors)))
-			idx = idx % len(cfg.Colors)
-			
-			grid[y][x] = idx
+			f.Set(x, y, pixel(x, y))
 		}
 	}
-	return grid
-}
\ No newline at end of file
+	return f
+}
+
+// expressions repeat the palette every whole unit
+var expressionMapping = mapping{normalize: "wrap", gamma: 1}
+
+// GenerateFromExpression renders a custom Expression as an image indexing cfg.Colors
+// honours cfg.Tileable by blending across the edges and cfg.Dither
+func GenerateFromExpression(cfg config.Config, expr Expression) *raster.Image {
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	field := fillField(cfg, blendTile(cfg, func(x, y float64) float64 {
+		return expr.Eval(x, y, w, h)
+	}))
+	return expressionMapping.image(field, cfg)
+}
@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	"xpm-gen/internal/raster"
+)
+
+// DitherModes lists the values accepted by -dither
+var DitherModes = []string{"none", "bayer2", "bayer4", "bayer8", "bluenoise", "floyd-steinberg", "atkinson", "sierra"}
+
+// one neighbour receiving a share of the quantization error
+type diffusion struct {
+	dx, dy int
+	weight float64
+}
+
+// error diffusion kernels, weights already divided by the kernel total
+var diffusionKernels = map[string][]diffusion{
+	"floyd-steinberg": {
+		{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	// atkinson only passes on 6/8 of the error, which keeps highlights clean
+	"atkinson": {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	"sierra": {
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	},
+}
+
+// maps row-major 0..1 values onto levels 0..levels-1 of an image
+// "none" (or "") keeps the plain int(v * levels) banding, every other mode
+// treats the levels as a ramp from 0 to 1 and dithers between neighbouring steps
+// values at or below 0 are background: they stay level 0 and never take on error
+// takes: image of the values' size (all level 0), values, number of levels, dither mode
+// mutates: img
+func ditherField(img *raster.Image, values []float64, levels int, mode string) {
+	width, height := img.Width, img.Height
+	if levels < 2 {
+		return
+	}
+	steps := float64(levels - 1)
+
+	if kernel, ok := diffusionKernels[mode]; ok {
+		// error pushed ahead onto pixels not visited yet
+		carry := make([]float64, len(values))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := values[y*width+x]
+				if v <= 0 {
+					continue
+				}
+				want := v + carry[y*width+x]
+				level := clampLevel(int(want*steps+0.5), levels)
+				img.Set(x, y, level)
+				diff := want - float64(level)/steps
+				for _, d := range kernel {
+					nx, ny := x+d.dx, y+d.dy
+					if ny < height && nx >= 0 && nx < width {
+						carry[ny*width+nx] += diff * d.weight
+					}
+				}
+			}
+		}
+		return
+	}
+
+	threshold := orderedThresholds(mode)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := values[y*width+x]
+			if threshold == nil {
+				img.Set(x, y, clampLevel(int(v*float64(levels)), levels))
+				continue
+			}
+			if v <= 0 {
+				continue
+			}
+			size := len(threshold)
+			img.Set(x, y, clampLevel(int(v*steps+threshold[y%size][x%size]), levels))
+		}
+	}
+}
+
+// keeps a level inside 0..levels-1
+func clampLevel(level, levels int) int {
+	return max(0, min(level, levels-1))
+}
+
+// returns the tiled threshold map of an ordered dither mode, nil for none
+// every entry sits strictly inside 0..1
+func orderedThresholds(mode string) [][]float64 {
+	switch mode {
+	case "bayer2":
+		return bayerMatrix(2)
+	case "bayer4":
+		return bayerMatrix(4)
+	case "bayer8":
+		return bayerMatrix(8)
+	case "bluenoise":
+		return blueNoise()
+	}
+	return nil
+}
+
+// builds the recursive bayer index matrix, scaled to thresholds
+// takes: size (a power of two)
+// returns: size x size thresholds
+func bayerMatrix(size int) [][]float64 {
+	index := [][]int{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]int, 2*n)
+		for y := range next {
+			next[y] = make([]int, 2*n)
+			for x := range next[y] {
+				// each quadrant interleaves the previous matrix: 0 2 / 3 1
+				quadrant := [2][2]int{{0, 2}, {3, 1}}[y/n][x/n]
+				next[y][x] = 4*index[y%n][x%n] + quadrant
+			}
+		}
+		index = next
+	}
+	return rankThresholds(index)
+}
+
+// size of the generated blue noise tile
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseTile [][]float64
+)
+
+// returns the blue noise threshold tile, built once on first use
+func blueNoise() [][]float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseTile = rankThresholds(voidAndCluster(blueNoiseSize, 1.5))
+	})
+	return blueNoiseTile
+}
+
+// ranks every cell of a torus with ulichney's void-and-cluster method
+// so that any prefix of the ranking is spread out as evenly as possible
+// a fixed seed keeps the tile, and so every dithered render, reproducible
+// takes: tile size, gaussian sigma
+// returns: size x size ranks 0..size*size-1
+func voidAndCluster(size int, sigma float64) [][]int {
+	cells := size * size
+	// gaussian falloff by wrapped offset
+	weight := make([]float64, cells)
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			wx, wy := float64(min(dx, size-dx)), float64(min(dy, size-dy))
+			weight[dy*size+dx] = math.Exp(-(wx*wx + wy*wy) / (2 * sigma * sigma))
+		}
+	}
+
+	on := make([]bool, cells)
+	energy := make([]float64, cells)
+	toggle := func(i int, set bool) {
+		on[i] = set
+		sign := 1.0
+		if !set {
+			sign = -1
+		}
+		ix, iy := i%size, i/size
+		for j := range energy {
+			dx, dy := (j%size-ix+size)%size, (j/size-iy+size)%size
+			energy[j] += sign * weight[dy*size+dx]
+		}
+	}
+	// tightest cluster: the set cell with the most energy
+	// largest void: the empty cell with the least
+	extreme := func(set bool) int {
+		best := -1
+		for i, e := range energy {
+			if on[i] != set {
+				continue
+			}
+			if best < 0 || (set && e > energy[best]) || (!set && e < energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// random start, then relax until moving the tightest cell doesn't change anything
+	rng := rand.New(rand.NewSource(1))
+	initial := cells / 10
+	for _, i := range rng.Perm(cells)[:initial] {
+		toggle(i, true)
+	}
+	for {
+		cluster := extreme(true)
+		toggle(cluster, false)
+		void := extreme(false)
+		if void == cluster {
+			toggle(cluster, true)
+			break
+		}
+		toggle(void, true)
+	}
+	start := append([]bool(nil), on...)
+	startEnergy := append([]float64(nil), energy...)
+
+	rank := make([]int, cells)
+	// ranks below the start: peel off the tightest clusters
+	for r := initial - 1; r >= 0; r-- {
+		i := extreme(true)
+		toggle(i, false)
+		rank[i] = r
+	}
+	// ranks above: keep filling the largest void
+	copy(on, start)
+	copy(energy, startEnergy)
+	for r := initial; r < cells; r++ {
+		i := extreme(false)
+		toggle(i, true)
+		rank[i] = r
+	}
+
+	out := make([][]int, size)
+	for y := range out {
+		out[y] = rank[y*size : (y+1)*size]
+	}
+	return out
+}
+
+// turns a matrix of ranks 0..n-1 into thresholds (rank + 0.5) / n
+func rankThresholds(ranks [][]int) [][]float64 {
+	n := float64(len(ranks) * len(ranks[0]))
+	out := make([][]float64, len(ranks))
+	for y, row := range ranks {
+		out[y] = make([]float64, len(row))
+		for x, r := range row {
+			out[y][x] = (float64(r) + 0.5) / n
+		}
+	}
+	return out
+}
@@ -92,36 +92,38 @@ func (n UnaryNode) String() string {
 }
 
 // GenerateRandomExpression builds a random AST
-func GenerateRandomExpression(depth int) Expression {
-	if depth <= 0 || (depth > 1 && rand.Float64() < 0.2) {
+// all choices are drawn from rng so a seed reproduces the same tree
+func GenerateRandomExpression(rng *rand.Rand, depth int) Expression {
+	if depth <= 0 || (depth > 1 && rng.Float64() < 0.2) {
 		// Terminal node
-		if rand.Float64() < 0.5 {
-			return ValNode{Value: rand.Float64() * 5}
+		if rng.Float64() < 0.5 {
+			// round to what String() prints so saved .algo files re-render identically
+			return ValNode{Value: math.Round(rng.Float64()*500) / 100}
 		}
-		if rand.Float64() < 0.5 {
+		if rng.Float64() < 0.5 {
 			return VarNode{Name: "x"}
 		}
 		return VarNode{Name: "y"}
 	}
 
 	// Operator node
-	r := rand.Float64()
+	r := rng.Float64()
 	if r < 0.6 {
 		// Binary
 		ops := []string{"+", "-", "*", "/", "%", "xor"}
-		op := ops[rand.Intn(len(ops))]
+		op := ops[rng.Intn(len(ops))]
 		return OpNode{
 			Op:    op,
-			Left:  GenerateRandomExpression(depth - 1),
-			Right: GenerateRandomExpression(depth - 1),
+			Left:  GenerateRandomExpression(rng, depth-1),
+			Right: GenerateRandomExpression(rng, depth-1),
 		}
 	} else {
 		// Unary
 		ops := []string{"sin", "cos", "abs", "tan"}
-		op := ops[rand.Intn(len(ops))]
+		op := ops[rng.Intn(len(ops))]
 		return UnaryNode{
 			Op:   op,
-			Expr: GenerateRandomExpression(depth - 1),
+			Expr: GenerateRandomExpression(rng, depth-1),
 		}
 	}
 }
@@ -0,0 +1,241 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseError points at the column (1-based) where parsing failed
+type ParseError struct {
+	Col int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Col, e.Msg)
+}
+
+// ParseExpression reads an expression back from its String() form
+// also accepts hand-written input with the usual precedence, e.g.
+// "sin(x * 3) + abs(y - 0.5) xor 0.25"
+//
+// grammar:
+//
+//	expr   := term (("+" | "-") term)*
+//	term   := factor (("*" | "/" | "%" | "xor") factor)*
+//	factor := number | "x" | "y" | unary "(" expr ")" | "(" expr ")" | "-" factor
+//	unary  := "sin" | "cos" | "abs" | "tan"
+func ParseExpression(src string) (Expression, error) {
+	p := &exprParser{src: src}
+	p.next()
+	if p.tok.kind == tokEOF {
+		return nil, &ParseError{Col: p.tok.col, Msg: "empty expression"}
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s after expression", p.tok)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	col  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+type exprParser struct {
+	src string
+	pos int
+	tok token
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return &ParseError{Col: p.tok.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// advances p.tok to the next token in the source
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	col := start + 1
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, col: col}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// optional exponent, e.g. 1e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && p.src[end] >= '0' && p.src[end] <= '9' {
+				p.pos = end
+				for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+					p.pos++
+				}
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], col: col}
+	case unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], col: col}
+	case strings.ContainsRune("+-*/%", rune(c)):
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), col: col}
+	case c == '(':
+		p.pos++
+		p.tok = token{kind: tokLParen, text: "(", col: col}
+	case c == ')':
+		p.pos++
+		p.tok = token{kind: tokRParen, text: ")", col: col}
+	default:
+		p.pos++
+		p.tok = token{kind: tokInvalid, text: string(c), col: col}
+	}
+}
+
+func (p *exprParser) parseExpr() (Expression, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = OpNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseTerm() (Expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.tok.kind == tokOp && strings.Contains("*/%", p.tok.text):
+			op = p.tok.text
+		case p.tok.kind == tokIdent && p.tok.text == "xor":
+			op = "xor"
+		default:
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = OpNode{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *exprParser) parseFactor() (Expression, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number '%s'", tok.text)
+		}
+		p.next()
+		return ValNode{Value: v}, nil
+
+	case tokOp:
+		if tok.text != "-" {
+			return nil, p.errorf("unexpected '%s', expected a value", tok.text)
+		}
+		p.next()
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		// fold negative literals so "-1.50" round-trips as a ValNode
+		if v, ok := operand.(ValNode); ok {
+			return ValNode{Value: -v.Value}, nil
+		}
+		return OpNode{Op: "-", Left: ValNode{Value: 0}, Right: operand}, nil
+
+	case tokLParen:
+		p.next()
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ')' to close '(' at col %d, got %s", tok.col, p.tok)
+		}
+		p.next()
+		return inner, nil
+
+	case tokIdent:
+		name := strings.ToLower(tok.text)
+		switch name {
+		case "x", "y":
+			p.next()
+			return VarNode{Name: name}, nil
+		case "sin", "cos", "abs", "tan":
+			p.next()
+			if p.tok.kind != tokLParen {
+				return nil, p.errorf("expected '(' after %s, got %s", name, p.tok)
+			}
+			open := p.tok
+			p.next()
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokRParen {
+				return nil, p.errorf("expected ')' to close '(' at col %d, got %s", open.col, p.tok)
+			}
+			p.next()
+			return UnaryNode{Op: name, Expr: arg}, nil
+		}
+		return nil, p.errorf("unknown name '%s' (want x, y, sin, cos, abs, tan or xor)", tok.text)
+
+	case tokEOF:
+		return nil, p.errorf("unexpected end of input, expected a value")
+	}
+	return nil, p.errorf("unexpected %s, expected a value", tok)
+}
@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+// Field is a continuous texture before it is mapped onto the palette
+// generators built on one return raw values (densities, concentrations,
+// noise) and leave normalizing, thresholds and dithering to the mapping stage
+type Field struct {
+	Width, Height int
+	Values        []float64 // row-major, Width*Height
+	// optional split of the palette into several ramps sharing the
+	// background color, Ramp picks one per pixel (nil = everything on ramp 0)
+	Ramps int
+	Ramp  []int
+}
+
+// allocates an all-zero field
+func NewField(width, height int) *Field {
+	return &Field{Width: width, Height: height, Values: make([]float64, width*height)}
+}
+
+func (f *Field) At(x, y int) float64     { return f.Values[y*f.Width+x] }
+func (f *Field) Set(x, y int, v float64) { f.Values[y*f.Width+x] = v }
+
+// the ways a field can be normalized to 0..1
+// fixed: the generator's own lo..hi range
+// linear: stretches the lowest..highest value over the palette
+// log: log scale from the smallest positive value to the highest, 0 stays background
+// equalize: histogram equalization, every color covers about the same area
+// wrap: only the fractional part counts, the palette repeats every whole unit
+var normalizeModes = []string{"fixed", "linear", "log", "equalize", "wrap"}
+
+// how a generator's field turns into palette indices
+// the defaults come from the generator, -param normalize/gamma/threshold override them
+type mapping struct {
+	normalize string
+	lo, hi    float64 // input range for fixed
+	gamma     float64 // curve applied after normalizing, 1 = straight
+	threshold float64 // normalized values below it become background
+}
+
+// builds the parameters that let the user override a generator's mapping
+// takes: the generator's default mapping
+// returns: normalize, gamma and threshold params
+func mappingParams(m mapping) []Param {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	return []Param{
+		{Name: "normalize", Kind: ParamChoice, Default: m.normalize, Choices: normalizeModes, Usage: "scaling onto the palette: the generator's range, min..max stretch, log, histogram equalization or fractional part"},
+		{Name: "gamma", Kind: ParamFloat, Default: format(m.gamma), Usage: "curve after normalizing, below 1 brightens, above 1 darkens"},
+		{Name: "threshold", Kind: ParamFloat, Default: format(m.threshold), Usage: "normalized values below this become the background color (0-1)"},
+	}
+}
+
+// resolves the mapping of one run
+// takes: generator name (for errors), params, default mapping
+// returns: mapping or an error for out of range values
+func mappingFor(name string, p params, m mapping) (mapping, error) {
+	m.normalize = p.String("normalize")
+	m.gamma = p.Float("gamma")
+	m.threshold = p.Float("threshold")
+	switch {
+	case m.gamma <= 0:
+		return m, fmt.Errorf("%s: gamma must be above 0", name)
+	case m.threshold < 0 || m.threshold > 1:
+		return m, fmt.Errorf("%s: threshold must be between 0 and 1", name)
+	}
+	return m, nil
+}
+
+// maps a field onto palette indices
+// normalizes, clamps to 0..1, applies the threshold and gamma, then dithers
+// every ramp gets an equal share of the palette after the background color
+// takes: field, config (palette size and dither mode)
+// returns: fresh image indexing cfg.Colors
+func (m mapping) image(f *Field, cfg config.Config) *raster.Image {
+	values := m.normalized(f.Values)
+	for i, v := range values {
+		v = max(0, min(v, 1))
+		if v < m.threshold || math.IsNaN(v) {
+			v = 0
+		}
+		if m.gamma != 1 {
+			v = math.Pow(v, m.gamma)
+		}
+		values[i] = v
+	}
+
+	img := raster.New(f.Width, f.Height, cfg.Colors)
+	if f.Ramp == nil {
+		ditherField(img, values, len(cfg.Colors), cfg.Dither)
+		return img
+	}
+	// level 0 is the background, 1..ramp step up the pixel's ramp
+	// colors past the last full ramp stay unused
+	ramp := (len(cfg.Colors) - 1) / max(f.Ramps, 1)
+	ditherField(img, values, ramp+1, cfg.Dither)
+	for i, level := range img.Pix {
+		if level > 0 {
+			img.Pix[i] = uint16(f.Ramp[i]*ramp) + level
+		}
+	}
+	return img
+}
+
+// scales raw values towards 0..1 with the mapping's normalize mode
+// takes: raw values
+// returns: fresh slice of normalized values (not clamped yet)
+func (m mapping) normalized(raw []float64) []float64 {
+	out := make([]float64, len(raw))
+	if len(raw) == 0 {
+		return out
+	}
+	switch m.normalize {
+	case "fixed", "linear":
+		lo, hi := m.lo, m.hi
+		if m.normalize == "linear" {
+			lo, hi = math.Inf(1), math.Inf(-1)
+			for _, v := range raw {
+				lo = math.Min(lo, v)
+				hi = math.Max(hi, v)
+			}
+		}
+		scale := 0.0
+		if hi > lo {
+			scale = 1 / (hi - lo)
+		}
+		for i, v := range raw {
+			out[i] = (v - lo) * scale
+		}
+	case "log":
+		// scaled so the faintest value sits at 1, counts are left as they are
+		floor, top := math.Inf(1), 0.0
+		for _, v := range raw {
+			if v > 0 {
+				floor = math.Min(floor, v)
+				top = math.Max(top, v)
+			}
+		}
+		for i, v := range raw {
+			switch {
+			case v <= 0:
+				out[i] = 0
+			case top > floor:
+				out[i] = math.Log(v/floor) / math.Log(top/floor)
+			default:
+				// a single level everywhere has no log range yet
+				out[i] = 1
+			}
+		}
+	case "equalize":
+		// position of each value in the sorted field, the lowest value stays 0
+		sorted := slices.Clone(raw)
+		slices.Sort(sorted)
+		lowest := sort.SearchFloat64s(sorted, math.Nextafter(sorted[0], math.Inf(1)))
+		span := float64(len(sorted) - lowest)
+		for i, v := range raw {
+			below := sort.Search(len(sorted), func(j int) bool { return sorted[j] > v })
+			if span > 0 {
+				out[i] = float64(below-lowest) / span
+			}
+		}
+	case "wrap":
+		for i, v := range raw {
+			v = math.Abs(v)
+			out[i] = v - math.Floor(v)
+		}
+	}
+	return out
+}
@@ -1,52 +1,258 @@
 package generator
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 )
 
-// calculates pixel color for mandelbrot set
-// applies zoom and random color offset for variety
-// takes: x/y coords, config, zoom factor, random offset
-// returns: color index
-func mandelbrot(x, y int, cfg config.Config, zoom float64, randOffset int) int {
-	scaleX := 3.5 / zoom
-	scaleY := 2.0 / zoom
-	offsetX := -2.5 + (1.0 - (1.0 / zoom))
-	jx := float64(x)/float64(cfg.Width)*scaleX + offsetX
-	jy := float64(y)/float64(cfg.Height)*scaleY - (scaleY / 2.0)
-
-	zx, zy := 0.0, 0.0
-	iter := 0
-	maxIter := 50
-	for iter < maxIter && (zx*zx+zy*zy) < 4.0 {
-		tmp := zx*zx - zy*zy + jx
-		zy = 2.0*zx*zy + jy
-		zx = tmp
-		iter++
+func init() {
+	Register(builtin{
+		name:        "mandelbrot",
+		description: "escape-time mandelbrot (or multibrot) set, random zoom unless a region is given",
+		palette:     neonPalette,
+		params:      mandelbrotParams,
+		expand:      smoothFractalPalette,
+		generate: func(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+			p := paramsFor(cfg, mandelbrotParams)
+			zoom := 0.5 + rng.Float64()
+			randOffset := rng.Intn(len(cfg.Colors))
+			if p.Has("zoom") {
+				zoom = p.Float("zoom")
+			}
+			f, err := fractalFor("mandelbrot", p, p.Float("cx"), p.Float("cy"), 3.5, zoom, randOffset)
+			if err != nil {
+				return nil, err
+			}
+			return fillImage(cfg, func(x, y int) int {
+				return f.pixel(x, y, cfg, func(px, py float64) (float64, bool) {
+					// z starts at 0, the pixel is c
+					return f.escape(0, 0, px, py)
+				})
+			}), nil
+		},
+	})
+	Register(builtin{
+		name:        "julia",
+		description: "escape-time julia set for a random or given constant c",
+		palette:     neonPalette,
+		params:      juliaParams,
+		expand:      smoothFractalPalette,
+		generate: func(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+			p := paramsFor(cfg, juliaParams)
+			cre := (rng.Float64() * 2.0) - 1.0
+			cim := (rng.Float64() * 2.0) - 1.0
+			randOffset := rng.Intn(len(cfg.Colors))
+			if p.Has("cre") {
+				cre = p.Float("cre")
+			}
+			if p.Has("cim") {
+				cim = p.Float("cim")
+			}
+			f, err := fractalFor("julia", p, p.Float("cx"), p.Float("cy"), 3.0, p.Float("zoom"), randOffset)
+			if err != nil {
+				return nil, err
+			}
+			return fillImage(cfg, func(x, y int) int {
+				return f.pixel(x, y, cfg, func(px, py float64) (float64, bool) {
+					// the pixel is z, c is fixed
+					return f.escape(px, py, cre, cim)
+				})
+			}), nil
+		},
+	})
+}
+
+// parameters shared by both escape-time fractals
+var fractalParams = []Param{
+	{Name: "maxiter", Kind: ParamInt, Default: "50", Usage: "iterations before a point counts as inside the set"},
+	{Name: "exponent", Kind: ParamFloat, Default: "2", Usage: "power in z^d + c, 3 and up give multibrot shapes"},
+	{Name: "smooth", Kind: ParamChoice, Default: "false", Choices: []string{"false", "true"}, Usage: "continuous coloring blended across an interpolated palette"},
+	{Name: "blend", Kind: ParamInt, Default: "16", Usage: "colors interpolated from each palette entry to the next when smooth"},
+	{Name: "aa", Kind: ParamInt, Default: "1", Usage: "supersampling, aa x aa samples per pixel (1-8)"},
+}
+
+var mandelbrotParams = append([]Param{
+	{Name: "cx", Kind: ParamFloat, Default: "-0.75", Usage: "real part of the view center"},
+	{Name: "cy", Kind: ParamFloat, Default: "0", Usage: "imaginary part of the view center"},
+	{Name: "zoom", Kind: ParamFloat, Default: "", Usage: "magnification, 1 shows 3.5 units across (random 0.5-1.5 when unset)"},
+}, fractalParams...)
+
+var juliaParams = append([]Param{
+	{Name: "cre", Kind: ParamFloat, Default: "", Usage: "real part of the constant c (random -1..1 when unset)"},
+	{Name: "cim", Kind: ParamFloat, Default: "", Usage: "imaginary part of the constant c (random -1..1 when unset)"},
+	{Name: "cx", Kind: ParamFloat, Default: "0", Usage: "real part of the view center"},
+	{Name: "cy", Kind: ParamFloat, Default: "0", Usage: "imaginary part of the view center"},
+	{Name: "zoom", Kind: ParamFloat, Default: "1", Usage: "magnification, 1 shows 3 units across"},
+}, fractalParams...)
+
+// the resolved view and coloring settings of one fractal render
+type fractal struct {
+	centerX, centerY float64
+	span             float64 // width of the view in the complex plane
+	maxIter          int
+	exponent         float64
+	smooth           bool
+	blend            int
+	aa               int
+	offset           int // rotates the color cycle
+}
+
+// resolves and checks the fractal parameters
+// takes: generator name (for errors), params, view center, width of the view at zoom 1, zoom, color offset
+// returns: settings or an error for out of range values
+func fractalFor(name string, p params, cx, cy, fullSpan, zoom float64, offset int) (fractal, error) {
+	f := fractal{
+		centerX:  cx,
+		centerY:  cy,
+		span:     fullSpan / zoom,
+		maxIter:  p.Int("maxiter"),
+		exponent: p.Float("exponent"),
+		smooth:   p.String("smooth") == "true",
+		blend:    p.Int("blend"),
+		aa:       p.Int("aa"),
+		offset:   offset,
 	}
-	if iter == maxIter {
-		return 0
+	switch {
+	case zoom <= 0:
+		return f, fmt.Errorf("%s: zoom must be above 0", name)
+	case f.maxIter < 1:
+		return f, fmt.Errorf("%s: maxiter must be at least 1", name)
+	case f.exponent <= 1:
+		return f, fmt.Errorf("%s: exponent must be above 1", name)
+	case f.blend < 1:
+		return f, fmt.Errorf("%s: blend must be at least 1", name)
+	case f.aa < 1 || f.aa > 8:
+		return f, fmt.Errorf("%s: aa must be between 1 and 8", name)
 	}
-	return ((iter + randOffset) % (len(cfg.Colors) - 1)) + 1
+	return f, nil
 }
 
-// calculates pixel color for julia set
-// uses random complex constants cx/cy to vary shape
-// takes: x/y coords, config, complex constants, random offset
-// returns: color index
-func julia(x, y int, cfg config.Config, cx, cy float64, randOffset int) int {
-	zx := float64(x)/float64(cfg.Width)*3.0 - 1.5
-	zy := float64(y)/float64(cfg.Height)*3.0 - 1.5
+// builds the palette for smooth coloring
+// index 0 stays the inside color, every other entry fades into the next
+// over blend colors (the last one back into the first)
+func smoothFractalPalette(colors []string, p params) ([]string, error) {
+	if p.String("smooth") != "true" || len(colors) < 2 {
+		return colors, nil
+	}
+	ramp, err := interpolatePalette(colors[1:], p.Int("blend"))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{colors[0]}, ramp...), nil
+}
+
+// iterates z -> z^d + c until |z| escapes or maxiter is reached
+// takes: starting z, constant c
+// returns: escape count (fractional when smooth) and false if z never escaped
+func (f fractal) escape(zx, zy, cx, cy float64) (float64, bool) {
+	// a big bailout keeps the smooth count continuous
+	bailout := 4.0
+	if f.smooth {
+		bailout = 65536.0
+	}
+	square := f.exponent == 2
+	whole := f.exponent == math.Trunc(f.exponent) && f.exponent <= 16
+
 	iter := 0
-	maxIter := 50
-	for iter < maxIter && (zx*zx+zy*zy) < 4.0 {
-		tmp := zx*zx - zy*zy + cx
-		zy = 2.0*zx*zy + cy
-		zx = tmp
+	for iter < f.maxIter && (zx*zx+zy*zy) < bailout {
+		switch {
+		case square:
+			zx, zy = zx*zx-zy*zy+cx, 2.0*zx*zy+cy
+		case whole:
+			// repeated complex multiplication, exact for integer powers
+			rx, ry := zx, zy
+			for k := 1; k < int(f.exponent); k++ {
+				rx, ry = rx*zx-ry*zy, rx*zy+ry*zx
+			}
+			zx, zy = rx+cx, ry+cy
+		default:
+			r := math.Pow(math.Hypot(zx, zy), f.exponent)
+			theta := math.Atan2(zy, zx) * f.exponent
+			zx, zy = r*math.Cos(theta)+cx, r*math.Sin(theta)+cy
+		}
 		iter++
 	}
-	if iter == maxIter {
+	if iter == f.maxIter {
+		return 0, false
+	}
+	if !f.smooth {
+		return float64(iter), true
+	}
+	// normalized iteration count: removes the integer banding
+	logZ := math.Log(zx*zx+zy*zy) / 2
+	return float64(iter) + 1 - math.Log(logZ/math.Log(2))/math.Log(f.exponent), true
+}
+
+// colors one pixel, averaging aa x aa samples
+// inside wins when most samples are inside, otherwise the outside samples
+// are averaged around the color cycle so neighbouring bands blend
+// with cfg.Tileable samples near the far edges are blended with the view
+// one period over (see tileSamples), weighted the same way
+// takes: pixel coords, config, escape function for a point of the plane
+// returns: color index
+func (f fractal) pixel(x, y int, cfg config.Config, escape func(px, py float64) (float64, bool)) int {
+	// everything after the inside color is one repeating cycle
+	entries := len(cfg.Colors) - 1
+	if entries < 1 {
 		return 0
 	}
-	return ((iter + randOffset) % (len(cfg.Colors) - 1)) + 1
-}
\ No newline at end of file
+	// every escape step moves one palette entry along, like the classic look
+	// smooth palettes hold blend colors per original entry
+	cycle := float64(entries)
+	if f.smooth {
+		cycle = float64(max(entries/f.blend, 1))
+	}
+
+	scale := f.span / float64(cfg.Width)
+	sample := func(sx, sy float64) (float64, bool) {
+		px := f.centerX + (sx-float64(cfg.Width)/2)*scale
+		py := f.centerY + (sy-float64(cfg.Height)/2)*scale
+		mu, escaped := escape(px, py)
+		// position around the cycle, 0..1 wraps
+		return (mu + float64(f.offset)) / cycle, escaped
+	}
+
+	var samples []tileSample
+	for sy := 0; sy < f.aa; sy++ {
+		for sx := 0; sx < f.aa; sx++ {
+			// sample centers spread evenly over the pixel
+			ox, oy := (float64(sx)+0.5)/float64(f.aa)-0.5, (float64(sy)+0.5)/float64(f.aa)-0.5
+			samples = append(samples, tileSamples(float64(x)+ox, float64(y)+oy, cfg)...)
+		}
+	}
+
+	var pos float64
+	if len(samples) == 1 {
+		p, escaped := sample(samples[0].x, samples[0].y)
+		if !escaped {
+			return 0
+		}
+		pos = p - math.Floor(p)
+	} else {
+		var inside, total, sumSin, sumCos float64
+		for _, s := range samples {
+			total += s.weight
+			p, escaped := sample(s.x, s.y)
+			if !escaped {
+				inside += s.weight
+				continue
+			}
+			sumSin += s.weight * math.Sin(2*math.Pi*p)
+			sumCos += s.weight * math.Cos(2*math.Pi*p)
+		}
+		if inside*2 > total {
+			return 0
+		}
+		// circular mean, so samples on both sides of the wrap average correctly
+		pos = math.Atan2(sumSin, sumCos) / (2 * math.Pi)
+		pos -= math.Floor(pos)
+	}
+
+	if f.smooth {
+		return min(int(pos*float64(entries)), entries-1) + 1
+	}
+	return int(math.Round(pos*float64(entries)))%entries + 1
+}
@@ -0,0 +1,23 @@
+package generator
+
+import (
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+// hands a snapshot to cfg.OnFrame on every FrameEvery-th step and on the last one
+// render is only called when a frame is wanted, so simulations pay nothing
+// when nobody is listening; it must return a fresh image the callback may keep
+// takes: config, completed steps (0 = initial state), whether this is the final step, renderer
+func emitFrame(cfg config.Config, step int, last bool, render func() *raster.Image) {
+	if cfg.OnFrame == nil {
+		return
+	}
+	every := cfg.FrameEvery
+	if every < 1 {
+		every = 1
+	}
+	if step%every == 0 || last {
+		cfg.OnFrame(step, render())
+	}
+}
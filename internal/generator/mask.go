@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"xpm-gen/internal/importer"
+)
+
+// reads an xpm as an on/off mask scaled to the texture size
+// the first palette entry and transparent pixels count as background
+// takes: path, texture width and height
+// returns: mask[y][x], true where the xpm has a foreground pixel
+func maskFromXPM(path string, width, height int) ([][]bool, error) {
+	data, err := importer.ReadXPM(path)
+	if err != nil {
+		return nil, err
+	}
+	img := data.Image()
+	mask := make([][]bool, height)
+	for y := 0; y < height; y++ {
+		mask[y] = make([]bool, width)
+		for x := 0; x < width; x++ {
+			// nearest neighbour scaling to the texture size
+			idx := img.At(x*img.Width/width, y*img.Height/height)
+			mask[y][x] = idx != 0 && img.Palette[idx] != "None"
+		}
+	}
+	return mask, nil
+}
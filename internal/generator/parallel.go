@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"runtime"
+	"sync"
+)
+
+// resolves the worker count from the config
+// takes: requested workers (0 or less means one per cpu)
+// returns: number of goroutines to use
+func workerCount(n int) int {
+	if n <= 0 {
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// splits rows 0..height into contiguous bands and runs fn on each band concurrently
+// fn must only write rows inside [y0, y1) and only read shared state,
+// so the result does not depend on how the rows are split
+// takes: height, worker count, band function
+func parallelRows(height, workers int, fn func(y0, y1 int)) {
+	workers = workerCount(workers)
+	if workers > height {
+		workers = height
+	}
+	if workers <= 1 {
+		fn(0, height)
+		return
+	}
+
+	var wg sync.WaitGroup
+	band := (height + workers - 1) / workers
+	for y0 := 0; y0 < height; y0 += band {
+		y1 := y0 + band
+		if y1 > height {
+			y1 = height
+		}
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			fn(y0, y1)
+		}(y0, y1)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,87 @@
+package generator
+
+import (
+	"math/rand"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
+)
+
+// the simulations split into row bands by parallelRows
+var parallelSims = []struct {
+	algo  string
+	steps string // "" for melting, which picks its own generation count
+}{
+	{"coral", "200"},
+	{"physarum", "100"},
+	{"melting", ""},
+}
+
+// builds the config of one run
+func simConfig(algo, steps string, size, workers int) config.Config {
+	g, _ := Lookup(algo)
+	params := map[string]string{}
+	if steps != "" {
+		params["steps"] = steps
+	}
+	cfg := config.Config{
+		Width:     size,
+		Height:    size,
+		Algorithm: algo,
+		Seed:      7,
+		Params:    params,
+		Workers:   workers,
+	}
+	cfg.Colors, _ = ExpandPalette(g, g.Palette(rand.New(rand.NewSource(cfg.Seed))), params)
+	return cfg
+}
+
+// renders one run, failing the test on errors
+func render(tb testing.TB, cfg config.Config) *raster.Image {
+	tb.Helper()
+	img, err := GenerateImage(cfg)
+	if err != nil {
+		tb.Fatalf("%s: %v", cfg.Algorithm, err)
+	}
+	return img
+}
+
+func TestParallelMatchesSerial(t *testing.T) {
+	for _, sim := range parallelSims {
+		t.Run(sim.algo, func(t *testing.T) {
+			steps := ""
+			if sim.steps != "" {
+				steps = "30"
+			}
+			serial := render(t, simConfig(sim.algo, steps, 48, 1))
+			// odd worker counts leave a short last band
+			for _, workers := range []int{2, 3, runtime.NumCPU()} {
+				parallel := render(t, simConfig(sim.algo, steps, 48, workers))
+				if !slices.Equal(serial.Pix, parallel.Pix) {
+					t.Errorf("-j %d differs from -j 1", workers)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSimulations(b *testing.B) {
+	counts := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		counts = append(counts, n)
+	}
+	for _, sim := range parallelSims {
+		for _, workers := range counts {
+			cfg := simConfig(sim.algo, sim.steps, 256, workers)
+			b.Run(sim.algo+"/j="+strconv.Itoa(workers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					render(b, cfg)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xpm-gen/internal/config"
+)
+
+// how a parameter value is checked
+type ParamKind int
+
+const (
+	ParamFloat  ParamKind = iota // any number
+	ParamInt                     // whole number
+	ParamString                  // free text, e.g. a file path
+	ParamChoice                  // one of Param.Choices
+)
+
+// checks user supplied parameters against a generator's schema
+// takes: generator, name -> value map (usually cfg.Params)
+// returns: error naming the first bad parameter or nil
+func CheckParams(g Generator, values map[string]string) error {
+	schema := g.Params()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names) // stable error messages
+
+	for _, name := range names {
+		p, ok := findParam(schema, name)
+		if !ok {
+			if len(schema) == 0 {
+				return fmt.Errorf("%s takes no parameters, got '%s'", g.Name(), name)
+			}
+			known := make([]string, len(schema))
+			for i, s := range schema {
+				known[i] = s.Name
+			}
+			return fmt.Errorf("%s has no parameter '%s' (want one of %s)", g.Name(), name, strings.Join(known, ", "))
+		}
+		if err := p.check(values[name]); err != nil {
+			return fmt.Errorf("%s: %v", g.Name(), err)
+		}
+	}
+	return nil
+}
+
+// validates one value against the parameter kind
+func (p Param) check(v string) error {
+	switch p.Kind {
+	case ParamFloat:
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("parameter '%s' wants a number, got '%s'", p.Name, v)
+		}
+	case ParamInt:
+		if _, err := strconv.Atoi(v); err != nil {
+			return fmt.Errorf("parameter '%s' wants a whole number, got '%s'", p.Name, v)
+		}
+	case ParamChoice:
+		for _, c := range p.Choices {
+			if v == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("parameter '%s' wants one of %s, got '%s'", p.Name, strings.Join(p.Choices, ", "), v)
+	}
+	return nil
+}
+
+func findParam(schema []Param, name string) (Param, bool) {
+	for _, p := range schema {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// resolved parameter values for one generator run
+// falls back to the schema default for anything the user didn't set
+type params struct {
+	values map[string]string
+	schema []Param
+}
+
+func paramsFor(cfg config.Config, schema []Param) params {
+	return params{values: cfg.Params, schema: schema}
+}
+
+// reports whether the user set the parameter explicitly
+func (p params) Has(name string) bool {
+	_, ok := p.values[name]
+	return ok
+}
+
+func (p params) String(name string) string {
+	if v, ok := p.values[name]; ok {
+		return v
+	}
+	def, _ := findParam(p.schema, name)
+	return def.Default
+}
+
+// values were checked by CheckParams, so parse errors can't happen here
+func (p params) Float(name string) float64 {
+	v, _ := strconv.ParseFloat(p.String(name), 64)
+	return v
+}
+
+func (p params) Int(name string) int {
+	v, _ := strconv.Atoi(p.String(name))
+	return v
+}
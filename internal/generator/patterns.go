@@ -4,13 +4,62 @@ import (
 	"math"
 	"math/rand"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 )
 
+func init() {
+	Register(builtin{
+		name:        "noise",
+		description: "plain white noise, every pixel picks a random color",
+		palette:     neonPalette,
+		generate: func(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+			return fillImage(cfg, func(x, y int) int { return noise(cfg, rng) }), nil
+		},
+	})
+	Register(builtin{
+		name:        "xor",
+		description: "bitwise xor munching squares",
+		palette:     neonPalette,
+		generate: func(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+			randX, randY := rng.Intn(1000), rng.Intn(1000)
+			if cfg.Tileable {
+				// x ^ y repeats seamlessly on a power of two torus,
+				// one period of it is stretched over the texture
+				pw, ph := floorPow2(cfg.Width), floorPow2(cfg.Height)
+				return fillImage(cfg, func(x, y int) int {
+					return xorPattern((x*pw/cfg.Width+randX)%pw, (y*ph/cfg.Height+randY)%ph, 0, 0, cfg)
+				}), nil
+			}
+			return fillImage(cfg, func(x, y int) int { return xorPattern(x, y, randX, randY, cfg) }), nil
+		},
+	})
+	Register(builtin{
+		name:        "circles",
+		description: "hypnotic concentric ripples around an off-center point",
+		palette:     neonPalette,
+		generate: func(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+			randX, randY := rng.Intn(1000), rng.Intn(1000)
+			randOffset := rng.Intn(len(cfg.Colors))
+			return fillImage(cfg, func(x, y int) int { return circles(x, y, randX, randY, randOffset, cfg) }), nil
+		},
+	})
+	Register(builtin{
+		name:        "pastel",
+		description: "domain-warped sine interference with a glassy look",
+		palette:     namedPalette("pastel"),
+		mapping:     mapping{normalize: "fixed", lo: 0, hi: 1, gamma: 0.8},
+		field: func(cfg config.Config, rng *rand.Rand, _ func(*Field) *raster.Image) (*Field, error) {
+			randX, randY := rng.Intn(1000), rng.Intn(1000)
+			return fillField(cfg, func(x, y int) float64 { return pastel(x, y, randX, randY, cfg) }), nil
+		},
+	})
+}
+
 // generates simple static noise
-// takes: config
+// takes: config, rng
 // returns: random color index
-func noise(cfg config.Config) int {
-	return rand.Intn(len(cfg.Colors))
+func noise(cfg config.Config, rng *rand.Rand) int {
+	return rng.Intn(len(cfg.Colors))
 }
 
 // generates bitwise xor fractal pattern
@@ -31,7 +80,12 @@ func circles(x, y, randX, randY, randOffset int, cfg config.Config) int {
 	offsetY := (float64(randY%100) / 50.0) * float64(cfg.Height) * 0.5
 	cx, cy := (float64(cfg.Width)/2)+offsetX, (float64(cfg.Height)/2)+offsetY
 	ringThickness := 1.0 + (float64(randX%10) / 2.0)
-	dist := math.Sqrt(math.Pow(float64(x)-cx, 2) + math.Pow(float64(y)-cy, 2))
+	dx, dy := float64(x)-cx, float64(y)-cy
+	if cfg.Tileable {
+		// rings around the nearest copy of the center on the torus
+		dx, dy = wrapDelta(dx, float64(cfg.Width)), wrapDelta(dy, float64(cfg.Height))
+	}
+	dist := math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
 	val := int(dist/ringThickness) + randOffset
 	return val % len(cfg.Colors)
 }
@@ -39,21 +93,22 @@ func circles(x, y, randX, randY, randOffset int, cfg config.Config) int {
 // generates domain-warped aesthetic textures
 // uses sine wave interference to create shiny/glassy look
 // takes: x/y coords, random offsets, config
-// returns: color index
-func pastel(x, y, randX, randY int, cfg config.Config) int {
+// returns: brightness 0..1, the mapping's 0.8 gamma then lifts the dark half
+func pastel(x, y, randX, randY int, cfg config.Config) float64 {
 	scale := 50.0
 	dx := float64(x + randX)
 	dy := float64(y + randY)
-	warpX := dx + 20.0*math.Sin(dy/60.0)
-	warpY := dy + 20.0*math.Cos(dx/60.0)
-	h := 0.5 + 0.5*math.Sin((warpX+warpY)/scale)
-	h = math.Pow(h, 0.8)
-	colorIdx := int(h * float64(len(cfg.Colors)))
-	if colorIdx < 0 {
-		return 0
-	}
-	if colorIdx >= len(cfg.Colors) {
-		return len(cfg.Colors) - 1
+	var h float64
+	if cfg.Tileable {
+		// same waves with wavelengths snapped to fit the texture a whole number of times
+		w, ht := cfg.Width, cfg.Height
+		warpX := dx + 20.0*math.Sin(dy/tileWavelength(60.0, ht))
+		warpY := dy + 20.0*math.Cos(dx/tileWavelength(60.0, w))
+		h = 0.5 + 0.5*math.Sin(warpX/tileWavelength(scale, w)+warpY/tileWavelength(scale, ht))
+	} else {
+		warpX := dx + 20.0*math.Sin(dy/60.0)
+		warpY := dy + 20.0*math.Cos(dx/60.0)
+		h = 0.5 + 0.5*math.Sin((warpX+warpY)/scale)
 	}
-	return colorIdx
+	return h
 }
\ No newline at end of file
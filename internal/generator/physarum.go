@@ -1,53 +1,144 @@
 package generator
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 	"xpm-gen/internal/config"
+	"xpm-gen/internal/raster"
 	"github.com/schollz/progressbar/v3"
 )
 
+func init() {
+	Register(builtin{
+		name:        "physarum",
+		description: "slime mold agents leaving vein-like transport networks",
+		palette:     physarumPalette,
+		params:      physarumParams,
+		expand:      speciesPalette,
+		animated:    true,
+		mapping:     physarumMapping,
+		field:       runPhysarum,
+	})
+}
+
+// generate a random neon gradient
+// black -> dark color -> bright color -> white
+func physarumPalette(rng *rand.Rand) []string {
+	baseHue := rng.Float64() * 360.0
+	colors := make([]string, 16)
+	colors[0] = "#000000" // background
+	for i := 1; i < 16; i++ {
+		// ramp up value and saturation
+		t := float64(i) / 15.0
+		// hue shifts slightly for interest
+		h := math.Mod(baseHue+(t*30.0), 360.0)
+		s := 100.0 - (t * 20.0) // desaturate slightly towards white
+		v := 30.0 + (t * 70.0)  // get brighter
+
+		// push the last few colors to pure white
+		if i > 13 {
+			s = 0
+			v = 100
+		}
+
+		colors[i] = hsvToHex(h, s, v)
+	}
+	return colors
+}
+
+// trails below 0.2 are background, which keeps the veins thin
+var physarumMapping = mapping{normalize: "fixed", lo: 0.2, hi: 1, gamma: 1}
+
+var physarumParams = []Param{
+	{Name: "sensorangle", Kind: ParamFloat, Default: "45", Usage: "angle between the middle and side sensors in degrees"},
+	{Name: "sensordist", Kind: ParamFloat, Default: "4", Usage: "how far ahead the sensors look in pixels"},
+	{Name: "turnangle", Kind: ParamFloat, Default: "45", Usage: "how far an agent turns per step in degrees"},
+	{Name: "decay", Kind: ParamFloat, Default: "0.9", Usage: "fraction of the trail left after each step (0-1)"},
+	{Name: "deposit", Kind: ParamFloat, Default: "0.2", Usage: "trail an agent leaves per step"},
+	{Name: "density", Kind: ParamFloat, Default: "0.12", Usage: "agents per pixel"},
+	{Name: "steps", Kind: ParamInt, Default: "500", Usage: "simulation steps"},
+	{Name: "species", Kind: ParamInt, Default: "1", Usage: "competing species, each with its own trail and color ramp (1-8)"},
+	{Name: "repel", Kind: ParamFloat, Default: "1", Usage: "how strongly a species avoids the trails of the others"},
+	{Name: "food", Kind: ParamString, Default: "", Usage: "xpm whose non-background pixels are food the agents are drawn to"},
+	{Name: "foodstrength", Kind: ParamFloat, Default: "0.5", Usage: "pull of the food scent compared to the trails"},
+}
+
 type Agent struct {
-	x, y  float64
-	angle float64
+	x, y    float64
+	angle   float64
+	species int
 }
 
 // simulates physarum polycephalum (slime mold) behavior
 // creates organic transport networks and vein-like structures
-func runPhysarum(cfg config.Config) [][]int {
+func runPhysarum(cfg config.Config, rng *rand.Rand, toImage func(*Field) *raster.Image) (*Field, error) {
 	width, height := cfg.Width, cfg.Height
+	p := paramsFor(cfg, physarumParams)
+
+	// simulation parameters, defaults tuned for ULTRA THIN lines
+	sensorAngle := p.Float("sensorangle") * (math.Pi / 180.0)
+	sensorDist := p.Float("sensordist")
+	turnAngle := p.Float("turnangle") * (math.Pi / 180.0)
+	decayFactor := p.Float("decay")
+	depositAmount := p.Float("deposit") // very low deposit => only heavy traffic survives
+	density := p.Float("density")
+	steps := p.Int("steps")
+	species := p.Int("species")
+	repel := p.Float("repel")
+	switch {
+	case sensorDist <= 0:
+		return nil, fmt.Errorf("physarum: sensordist must be above 0")
+	case decayFactor < 0 || decayFactor > 1:
+		return nil, fmt.Errorf("physarum: decay must be between 0 and 1")
+	case depositAmount <= 0:
+		return nil, fmt.Errorf("physarum: deposit must be above 0")
+	case density <= 0:
+		return nil, fmt.Errorf("physarum: density must be above 0")
+	case steps < 0:
+		return nil, fmt.Errorf("physarum: steps can't be negative")
+	case species < 1 || species > 8:
+		return nil, fmt.Errorf("physarum: species must be between 1 and 8")
+	}
+
+	// agents smell food from a distance but it never shows up in the image
+	var scent [][]float64
+	if p.Has("food") {
+		food, err := maskFromXPM(p.String("food"), width, height)
+		if err != nil {
+			return nil, fmt.Errorf("physarum: reading food: %w", err)
+		}
+		scent = foodScent(food, max(8, int(sensorDist*2)), p.Float("foodstrength"))
+	}
 	
-	// 1. init simulation state
-	trail := make([][]float64, height)
-	nextTrail := make([][]float64, height)
-	for y := 0; y < height; y++ {
-		trail[y] = make([]float64, width)
-		nextTrail[y] = make([]float64, width)
+	// 1. init simulation state, one trail map per species
+	trails := make([][][]float64, species)
+	nextTrails := make([][][]float64, species)
+	for s := range trails {
+		trails[s] = make([][]float64, height)
+		nextTrails[s] = make([][]float64, height)
+		for y := 0; y < height; y++ {
+			trails[s][y] = make([]float64, width)
+			nextTrails[s][y] = make([]float64, width)
+		}
 	}
 
 	// 2. spawn agents uniformly (no voids)
-	// standard density
-	numAgents := int(float64(width*height) * 0.12)
+	numAgents := int(float64(width*height) * density)
 	agents := make([]Agent, numAgents)
 	
 	for i := range agents {
 		agents[i] = Agent{
-			x: rand.Float64() * float64(width),
-			y: rand.Float64() * float64(height),
-			angle: rand.Float64() * 2 * math.Pi,
+			x: rng.Float64() * float64(width),
+			y: rng.Float64() * float64(height),
+			angle: rng.Float64() * 2 * math.Pi,
+			species: i % species,
 		}
 	}
-
-	// simulation parameters tuned for ULTRA THIN lines
-	sensorAngle := 45.0 * (math.Pi / 180.0)
-	sensorDist := 4.0 
-	turnAngle := 45.0 * (math.Pi / 180.0)
-	decayFactor := 0.9 
-	depositAmount := 0.2 // very low deposit => only heavy traffic survives
-	
-	steps := 500
 	
 	bar := progressbar.Default(int64(steps), "simulating physarum")
+	frame := func() *raster.Image { return toImage(trailField(trails)) }
+	emitFrame(cfg, 0, steps == 0, frame)
 
 	for step := 0; step < steps; step++ {
 		bar.Add(1)
@@ -56,14 +147,14 @@ func runPhysarum(cfg config.Config) [][]int {
 		for i := range agents {
 			a := &agents[i]
 			
-			l := sense(a, sensorDist, -sensorAngle, trail, width, height)
-			c := sense(a, sensorDist, 0, trail, width, height)
-			r := sense(a, sensorDist, sensorAngle, trail, width, height)
+			l := sense(a, sensorDist, -sensorAngle, trails, scent, repel, width, height)
+			c := sense(a, sensorDist, 0, trails, scent, repel, width, height)
+			r := sense(a, sensorDist, sensorAngle, trails, scent, repel, width, height)
 			
 			if c > l && c > r {
 				// straight
 			} else if c < l && c < r {
-				a.angle += (rand.Float64() - 0.5) * 2 * turnAngle
+				a.angle += (rng.Float64() - 0.5) * 2 * turnAngle
 			} else if l > r {
 				a.angle -= turnAngle
 			} else if r > l {
@@ -81,65 +172,147 @@ func runPhysarum(cfg config.Config) [][]int {
 			a.x = nextX
 			a.y = nextY
 			
-			ix, iy := int(nextX), int(nextY)
+			// wrapping can round up to exactly width/height
+			trail := trails[a.species]
+			ix, iy := wrapCoord(int(nextX), width), wrapCoord(int(nextY), height)
 			trail[iy][ix] += depositAmount
 			if trail[iy][ix] > 1.0 { trail[iy][ix] = 1.0 }
 		}
-		
+
 		// b. diffuse and decay
+		parallelRows(height, cfg.Workers, func(y0, y1 int) {
+			for s, trail := range trails {
+				nextTrail := nextTrails[s]
+				for y := y0; y < y1; y++ {
+					for x := 0; x < width; x++ {
+						sum := 0.0
+						for dy := -1; dy <= 1; dy++ {
+							for dx := -1; dx <= 1; dx++ {
+								ny := (y + dy + height) % height
+								nx := (x + dx + width) % width
+								sum += trail[ny][nx]
+							}
+						}
+						avg := sum / 9.0
+						nextTrail[y][x] = avg * decayFactor
+					}
+				}
+			}
+		})
+		trails, nextTrails = nextTrails, trails
+		emitFrame(cfg, step+1, step+1 == steps, frame)
+	}
+
+	return trailField(trails), nil
+}
+
+// reads the trail (and food scent, if any) under one sensor
+// an agent follows its own species and shies away from the others
+func sense(a *Agent, dist, angleOffset float64, trails [][][]float64, scent [][]float64, repel float64, w, h int) float64 {
+	sensorAngle := a.angle + angleOffset
+	sx := a.x + math.Cos(sensorAngle)*dist
+	sy := a.y + math.Sin(sensorAngle)*dist
+	
+	// floor, not truncation, so sensors just past the top/left edge read the
+	// opposite edge instead of row/column 0
+	ix := wrapCoord(int(math.Floor(sx)), w)
+	iy := wrapCoord(int(math.Floor(sy)), h)
+	
+	val := trails[a.species][iy][ix]
+	for s, trail := range trails {
+		if s != a.species {
+			val -= repel * trail[iy][ix]
+		}
+	}
+	if scent != nil {
+		val += scent[iy][ix]
+	}
+	return val
+}
+
+// spreads the food mask into a smooth scent field that fades with distance
+// takes: food mask, blur passes (roughly how far the scent carries), strength
+// returns: scent grid, strength at the heart of a food patch
+func foodScent(food [][]bool, passes int, strength float64) [][]float64 {
+	height, width := len(food), len(food[0])
+	scent := make([][]float64, height)
+	next := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		scent[y] = make([]float64, width)
+		next[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			if food[y][x] {
+				scent[y][x] = 1.0
+			}
+		}
+	}
+	for i := 0; i < passes; i++ {
 		for y := 0; y < height; y++ {
 			for x := 0; x < width; x++ {
 				sum := 0.0
 				for dy := -1; dy <= 1; dy++ {
 					for dx := -1; dx <= 1; dx++ {
-						ny := (y + dy + height) % height
-						nx := (x + dx + width) % width
-						sum += trail[ny][nx]
+						sum += scent[(y+dy+height)%height][(x+dx+width)%width]
 					}
 				}
-				avg := sum / 9.0
-				nextTrail[y][x] = avg * decayFactor
+				next[y][x] = sum / 9.0
+				// the food itself keeps smelling at full strength
+				if food[y][x] {
+					next[y][x] = 1.0
+				}
 			}
 		}
-		for y := 0; y < height; y++ {
-			copy(trail[y], nextTrail[y])
+		scent, next = next, scent
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scent[y][x] *= strength
 		}
 	}
+	return scent
+}
 
-	// convert
-	grid := make([][]int, height)
-	numColors := len(cfg.Colors)
+// builds one color ramp per species by turning the hue of the first ramp
+// index 0 stays the shared background
+func speciesPalette(colors []string, p params) ([]string, error) {
+	species := p.Int("species")
+	if species < 2 || len(colors) < 2 {
+		return colors, nil
+	}
+	out := append([]string(nil), colors...)
+	for s := 1; s < species; s++ {
+		for _, c := range colors[1:] {
+			turned, err := rotateHue(c, float64(s)*360.0/float64(species))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, turned)
+		}
+	}
+	return out, nil
+}
+
+// turns the trail maps into a field
+// each pixel shows the species with the strongest trail, on that species' ramp
+// takes: trail grids
+// returns: field of the strongest trail, one palette ramp per species
+func trailField(trails [][][]float64) *Field {
+	height, width := len(trails[0]), len(trails[0][0])
+	f := NewField(width, height)
+	f.Ramps = len(trails)
+	f.Ramp = make([]int, width*height)
 	
 	for y := 0; y < height; y++ {
-		grid[y] = make([]int, width)
 		for x := 0; x < width; x++ {
-			val := trail[y][x]
-			
-			// sharp threshold: cutoff at 0.2 to make lines thin
-			if val < 0.2 { 
-				val = 0 
-			} else { 
-				// stretch remainder
-				val = (val - 0.2) * 1.25 
+			species := 0
+			for s := range trails {
+				if trails[s][y][x] > trails[species][y][x] {
+					species = s
+				}
 			}
-			
-			idx := int(val * float64(numColors))
-			if idx >= numColors { idx = numColors - 1 }
-			if idx < 0 { idx = 0 }
-			grid[y][x] = idx
+			f.Ramp[y*width+x] = species
+			f.Values[y*width+x] = trails[species][y][x]
 		}
 	}
-
-	return grid
+	return f
 }
-
-func sense(a *Agent, dist, angleOffset float64, trail [][]float64, w, h int) float64 {
-	sensorAngle := a.angle + angleOffset
-	sx := a.x + math.Cos(sensorAngle)*dist
-	sy := a.y + math.Sin(sensorAngle)*dist
-	
-	ix := (int(sx) + w) % w
-	iy := (int(sy) + h) % h
-	
-	return trail[iy][ix]
-}
\ No newline at end of file
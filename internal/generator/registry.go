@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/palette"
+	"xpm-gen/internal/raster"
+)
+
+// Generator is implemented by every texture algorithm
+// adding an algorithm means writing one of these and registering it in init()
+type Generator interface {
+	// unique name used for -algo
+	Name() string
+	// one line summary shown in -algo list
+	Description() string
+	// palette used when the user doesn't pick one, may be randomized
+	Palette(rng *rand.Rand) []string
+	// smallest palette Generate can draw with, it writes fixed indices below this
+	MinColors() int
+	// tunable parameters understood by Generate
+	Params() []Param
+	// true for simulations that report intermediate frames through cfg.OnFrame
+	Animated() bool
+	// renders the texture as an image indexing cfg.Colors
+	Generate(cfg config.Config, rng *rand.Rand) (*raster.Image, error)
+}
+
+// optionally implemented by generators whose colors depend on their
+// parameters, e.g. smooth fractal shading blending between palette entries
+type PaletteExpander interface {
+	// derives the final palette from the chosen one (default or -randcolors)
+	ExpandPalette(colors []string, values map[string]string) ([]string, error)
+}
+
+// returns the palette a generator will actually render with
+// takes: generator, chosen palette, parameter values (usually cfg.Params)
+// returns: palette (unchanged for most generators) or error
+func ExpandPalette(g Generator, colors []string, values map[string]string) ([]string, error) {
+	if e, ok := g.(PaletteExpander); ok {
+		return e.ExpandPalette(colors, values)
+	}
+	return colors, nil
+}
+
+// describes one tunable generator parameter
+// set on the command line as -param name=value
+type Param struct {
+	Name    string
+	Kind    ParamKind
+	Default string
+	Choices []string // allowed values for ParamChoice
+	Usage   string
+}
+
+var registry = map[string]Generator{}
+
+// adds a generator to the registry
+// panics on duplicate names since that is always a programming error
+// mutates: registry
+func Register(g Generator) {
+	if _, ok := registry[g.Name()]; ok {
+		panic(fmt.Sprintf("generator %q registered twice", g.Name()))
+	}
+	registry[g.Name()] = g
+}
+
+// finds a registered generator by name
+func Lookup(name string) (Generator, bool) {
+	g, ok := registry[name]
+	return g, ok
+}
+
+// returns every registered generator sorted by name
+func All() []Generator {
+	all := make([]Generator, 0, len(registry))
+	for _, g := range registry {
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
+
+// returns every registered generator name, sorted
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for _, g := range All() {
+		names = append(names, g.Name())
+	}
+	return names
+}
+
+// adapts plain functions to the Generator interface
+// used by all the built-in algorithms
+type builtin struct {
+	name        string
+	description string
+	palette     func(rng *rand.Rand) []string
+	minColors   int // 0 = 1, enough for generators that only index by len(cfg.Colors)
+	params      []Param
+	animated    bool
+	expand      func(colors []string, p params) ([]string, error)
+	generate    func(cfg config.Config, rng *rand.Rand) (*raster.Image, error)
+
+	// continuous generators set field instead of generate and return raw
+	// values, mapped onto the palette with mapping (user adjustable through
+	// -param normalize/gamma/threshold), toImage maps intermediate frames the same way
+	mapping mapping
+	field   func(cfg config.Config, rng *rand.Rand, toImage func(*Field) *raster.Image) (*Field, error)
+}
+
+func (b builtin) Name() string                    { return b.name }
+func (b builtin) Description() string             { return b.description }
+func (b builtin) Palette(rng *rand.Rand) []string { return b.palette(rng) }
+func (b builtin) MinColors() int                  { return max(b.minColors, 1) }
+func (b builtin) Animated() bool                  { return b.animated }
+func (b builtin) Params() []Param {
+	if b.field == nil {
+		return b.params
+	}
+	return append(slices.Clip(b.params), mappingParams(b.mapping)...)
+}
+func (b builtin) Generate(cfg config.Config, rng *rand.Rand) (*raster.Image, error) {
+	if b.field == nil {
+		return b.generate(cfg, rng)
+	}
+	m, err := mappingFor(b.name, paramsFor(cfg, b.Params()), b.mapping)
+	if err != nil {
+		return nil, err
+	}
+	toImage := func(f *Field) *raster.Image { return m.image(f, cfg) }
+	f, err := b.field(cfg, rng, toImage)
+	if err != nil {
+		return nil, err
+	}
+	return toImage(f), nil
+}
+func (b builtin) ExpandPalette(colors []string, values map[string]string) ([]string, error) {
+	if b.expand == nil {
+		return colors, nil
+	}
+	return b.expand(colors, params{values: values, schema: b.Params()})
+}
+
+// wraps a constant palette so it fits builtin.palette
+func fixedPalette(colors ...string) func(rng *rand.Rand) []string {
+	return func(rng *rand.Rand) []string {
+		return append([]string(nil), colors...)
+	}
+}
+
+// wraps one of the named palettes so it fits builtin.palette
+func namedPalette(name string) func(rng *rand.Rand) []string {
+	p, ok := palette.Builtin(name)
+	if !ok {
+		panic("generator: no built-in palette " + name)
+	}
+	return fixedPalette(p.Colors...)
+}
+
+// the default neon palette shared by the simple pattern generators
+var neonPalette = namedPalette("neon")
@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"math"
+	"math/bits"
+
+	"xpm-gen/internal/config"
+)
+
+// share of the width and height, along the right and bottom edges, that fades
+// into the view continuing past the left and top edges
+const tileBlend = 0.25
+
+// one point a tileable pixel is blended from
+type tileSample struct {
+	x, y   float64
+	weight float64
+}
+
+// lists the points to blend for one pixel when cfg.Tileable is set
+// for generators with no natural period (fractals, expressions): inside a band
+// along the right and bottom edges the view fades into its copy shifted one
+// width left or one height up, so at the far edge it shows what lies just
+// before the near one, everything outside the band is left as it is
+// takes: pixel position, config
+// returns: one to four points with weights summing to 1 (the pixel itself when not tileable)
+func tileSamples(x, y float64, cfg config.Config) []tileSample {
+	if !cfg.Tileable {
+		return []tileSample{{x, y, 1}}
+	}
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	fx, fy := edgeFade(x, w), edgeFade(y, h)
+	samples := []tileSample{{x, y, (1 - fx) * (1 - fy)}}
+	if fx > 0 {
+		samples = append(samples, tileSample{x - w, y, fx * (1 - fy)})
+	}
+	if fy > 0 {
+		samples = append(samples, tileSample{x, y - h, (1 - fx) * fy})
+	}
+	if fx > 0 && fy > 0 {
+		samples = append(samples, tileSample{x - w, y - h, fx * fy})
+	}
+	return samples
+}
+
+// how far v is into the fading band before the far edge
+// 0 outside it, easing up to 1 at the edge itself
+func edgeFade(v, size float64) float64 {
+	band := tileBlend * size
+	t := (v - (size - band)) / band
+	if t <= 0 {
+		return 0
+	}
+	t = min(t, 1)
+	return t * t * (3 - 2*t)
+}
+
+// wraps a per-pixel field function so the texture tiles seamlessly when cfg.Tileable is set
+// values are blended across the edges as tileSamples describes
+// takes: config, field value at a point
+// returns: per-pixel function
+func blendTile(cfg config.Config, value func(x, y float64) float64) func(x, y int) float64 {
+	if !cfg.Tileable {
+		return func(x, y int) float64 { return value(float64(x), float64(y)) }
+	}
+	return func(x, y int) float64 {
+		v := 0.0
+		for _, s := range tileSamples(float64(x), float64(y), cfg) {
+			v += s.weight * value(s.x, s.y)
+		}
+		return v
+	}
+}
+
+// largest power of two no bigger than n (1 for n < 2)
+func floorPow2(n int) int {
+	if n < 2 {
+		return 1
+	}
+	return 1 << (bits.Len(uint(n)) - 1)
+}
+
+// shortest signed offset on a ring of length n
+// distances measured with it are continuous across the wrap (torus metric)
+func wrapDelta(d, n float64) float64 {
+	d = math.Mod(d, n)
+	if d > n/2 {
+		d -= n
+	} else if d < -n/2 {
+		d += n
+	}
+	return d
+}
+
+// wraps an integer coordinate into 0..n-1
+func wrapCoord(v, n int) int {
+	return (v%n + n) % n
+}
+
+// snaps a wavelength so a whole number of waves fits in the period
+// sin(x / snapped) then repeats exactly every period pixels
+// takes: wavelength in pixels, period in pixels
+// returns: snapped wavelength (divide coordinates by it)
+func tileWavelength(wavelength float64, period int) float64 {
+	waves := math.Round(float64(period) / (2 * math.Pi * wavelength))
+	if waves < 1 {
+		waves = 1
+	}
+	return float64(period) / (2 * math.Pi * waves)
+}
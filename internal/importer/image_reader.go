@@ -0,0 +1,29 @@
+package importer
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	// registers the decoders image.Decode picks from
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// decodes a png, gif or jpeg file, the format is sniffed from the content
+// gifs give their first frame
+// takes: path
+// returns: image or error
+func ReadImage(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return img, nil
+}
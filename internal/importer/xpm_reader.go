@@ -2,11 +2,15 @@ package importer
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
+
+	"xpm-gen/internal/colors"
+	"xpm-gen/internal/raster"
 )
 
 type XPMData struct {
@@ -14,101 +18,430 @@ type XPMData struct {
 	Height        int
 	NumColors     int
 	CharsPerPixel int
-	Colors        map[string]string // char -> hex color
+	Colors        map[string]string // char -> "#RRGGBB" or "None" for display (c, then g, g4, m)
 	PaletteKeys   []string          // ordered list of chars (to preserve order)
 	Pixels        []string          // raw pixel rows
+
+	// every visual of every palette entry: char -> key (c, m, g, g4, s) -> value
+	Visuals map[string]map[string]string
+
+	// optional hotspot from the header
+	HasHotspot bool
+	HotspotX   int
+	HotspotY   int
+
+	// XPMEXT blocks found after the pixels
+	Extensions []Extension
+}
+
+// one XPMEXT block: the name and the data lines that follow it
+type Extension struct {
+	Name  string
+	Lines []string
+}
+
+// SyntaxError reports where in the file parsing failed
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
 }
 
-// parses a simple xpm file
+// visual keys in the order we prefer them when picking a display color
+var visualPriority = []string{"c", "g", "g4", "m"}
+
+// an xpm string with the line it started on
+type xpmString struct {
+	text string
+	line int
+}
+
+// parses an xpm file
+// handles XPM3 (c source) and XPM2 ("! XPM2") files with every visual
+// (c, m, g, g4, s), hotspots and XPMEXT extensions
 func ReadXPM(filename string) (*XPMData, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return DecodeXPM(file, filename)
+}
 
-	scanner := bufio.NewScanner(file)
-	var contentLines []string
+// parses xpm data from a reader
+// takes: reader, name used in error messages
+// returns: parsed data or a *SyntaxError
+func DecodeXPM(r io.Reader, name string) (*XPMData, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var strs []xpmString
+	if isXPM2(src) {
+		strs = xpm2Strings(src)
+	} else {
+		strs, err = xpm3Strings(src, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p := &xpmParser{name: name, strs: strs}
+	return p.parse()
+}
 
-	// regex to find content inside double quotes
-	re := regexp.MustCompile(`"([^"]+)"`)
+// checks for the "! XPM2" magic on the first line
+func isXPM2(src []byte) bool {
+	first, _, _ := bytes.Cut(src, []byte("\n"))
+	return strings.HasPrefix(strings.TrimSpace(string(first)), "! XPM2")
+}
 
+// splits an XPM2 file into its raw lines
+// every line after the magic is a string, "!" starts a comment line
+func xpm2Strings(src []byte) []xpmString {
+	var strs []xpmString
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 64*1024), len(src)+1)
+	line := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			contentLines = append(contentLines, matches[1])
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 || strings.HasPrefix(text, "!") {
+			continue
 		}
+		strs = append(strs, xpmString{text: text, line: line})
 	}
+	return strs
+}
 
-	if len(contentLines) == 0 {
-		return nil, fmt.Errorf("no xpm data found in file")
+// pulls every string literal out of an XPM3 c source
+// skips comments and decodes c escapes so keys may contain quotes
+func xpm3Strings(src []byte, name string) ([]xpmString, error) {
+	var strs []xpmString
+	line := 1
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, &SyntaxError{File: name, Line: line, Msg: "unterminated comment"}
+			}
+			line += bytes.Count(src[i:i+2+end], []byte("\n"))
+			i += end + 3
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i-- // let the newline be counted above
+		case c == '"':
+			start := line
+			var sb strings.Builder
+			i++
+			for ; i < len(src) && src[i] != '"'; i++ {
+				switch src[i] {
+				case '\n':
+					return nil, &SyntaxError{File: name, Line: start, Msg: "unterminated string"}
+				case '\\':
+					if i+1 >= len(src) {
+						return nil, &SyntaxError{File: name, Line: start, Msg: "unterminated string"}
+					}
+					i++
+					sb.WriteByte(unescape(src[i]))
+				default:
+					sb.WriteByte(src[i])
+				}
+			}
+			if i >= len(src) {
+				return nil, &SyntaxError{File: name, Line: start, Msg: "unterminated string"}
+			}
+			strs = append(strs, xpmString{text: sb.String(), line: start})
+		}
+	}
+	if len(strs) == 0 {
+		return nil, &SyntaxError{File: name, Line: line, Msg: "no xpm data found in file"}
+	}
+	return strs, nil
+}
+
+// maps the char after a backslash to the byte it stands for
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	}
+	return c // \" \\ \' and anything unknown stand for themselves
+}
+
+type xpmParser struct {
+	name string
+	strs []xpmString
+	pos  int
+}
+
+func (p *xpmParser) errorf(line int, format string, args ...any) error {
+	return &SyntaxError{File: p.name, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// returns the next string or an error naming what was expected
+func (p *xpmParser) take(what string) (xpmString, error) {
+	if p.pos >= len(p.strs) {
+		line := 1
+		if len(p.strs) > 0 {
+			line = p.strs[len(p.strs)-1].line
+		}
+		return xpmString{}, p.errorf(line, "unexpected end of file, expected %s", what)
 	}
+	s := p.strs[p.pos]
+	p.pos++
+	return s, nil
+}
 
-	// 1. header: "width height num_colors chars_per_pixel"
-	headerParts := strings.Fields(contentLines[0])
-	if len(headerParts) < 4 {
-		return nil, fmt.Errorf("invalid xpm header: %s", contentLines[0])
+func (p *xpmParser) parse() (*XPMData, error) {
+	data, hasExt, err := p.parseHeader()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < data.NumColors; i++ {
+		if err := p.parseColor(data, i); err != nil {
+			return nil, err
+		}
+	}
+	for y := 0; y < data.Height; y++ {
+		if err := p.parsePixels(data, y); err != nil {
+			return nil, err
+		}
+	}
+	if hasExt {
+		if err := p.parseExtensions(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// 1. header: "width height ncolors cpp [x_hotspot y_hotspot] [XPMEXT]"
+func (p *xpmParser) parseHeader() (*XPMData, bool, error) {
+	s, err := p.take("header")
+	if err != nil {
+		return nil, false, err
+	}
+	fields := strings.Fields(s.text)
+	hasExt := false
+	if len(fields) > 0 && fields[len(fields)-1] == "XPMEXT" {
+		hasExt = true
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, false, p.errorf(s.line, "invalid header %q, want \"width height ncolors cpp [x_hot y_hot] [XPMEXT]\"", s.text)
 	}
 
-	w, _ := strconv.Atoi(headerParts[0])
-	h, _ := strconv.Atoi(headerParts[1])
-	nc, _ := strconv.Atoi(headerParts[2])
-	cpp, _ := strconv.Atoi(headerParts[3])
+	names := []string{"width", "height", "color count", "chars per pixel", "x hotspot", "y hotspot"}
+	values := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil || v < 0 {
+			return nil, false, p.errorf(s.line, "invalid %s %q in header", names[i], f)
+		}
+		values[i] = v
+	}
+	for i := 0; i < 4; i++ {
+		if values[i] == 0 {
+			return nil, false, p.errorf(s.line, "%s must be positive", names[i])
+		}
+	}
+	if values[2] > raster.MaxColors {
+		return nil, false, p.errorf(s.line, "%d colors is more than the %d supported", values[2], raster.MaxColors)
+	}
 
 	data := &XPMData{
-		Width:         w,
-		Height:        h,
-		NumColors:     nc,
-		CharsPerPixel: cpp,
+		Width:         values[0],
+		Height:        values[1],
+		NumColors:     values[2],
+		CharsPerPixel: values[3],
 		Colors:        make(map[string]string),
-		PaletteKeys:   make([]string, 0, nc),
-		Pixels:        make([]string, h),
-	}
-
-	// 2. palette
-	// we expect 'nc' lines of palette definition
-	// format usually: "char c color"
-	for i := 0; i < nc; i++ {
-		if 1+i >= len(contentLines) {
-			return nil, fmt.Errorf("unexpected end of file reading palette")
-		}
-		entry := contentLines[1+i]
-
-		// the char code is the first 'cpp' characters
-		if len(entry) < cpp {
-			return nil, fmt.Errorf("palette entry too short: %s", entry)
-		}
-		charCode := entry[:cpp]
-		
-		// find the color definition. we look for "c " (color visual)
-		// this is a simplification; xpm can have 'm', 'g', 'g4' etc.
-		// but for our generator's output, 'c' is standard.
-		parts := strings.Split(entry[cpp:], "c ")
-		if len(parts) < 2 {
-			// fallback: try splitting by spaces and taking the last part
-			fields := strings.Fields(entry)
-			if len(fields) >= 2 {
-				color := fields[len(fields)-1]
-				data.Colors[charCode] = color
-			} else {
-				return nil, fmt.Errorf("could not parse palette entry: %s", entry)
+		PaletteKeys:   make([]string, 0, values[2]),
+		Pixels:        make([]string, values[1]),
+		Visuals:       make(map[string]map[string]string),
+	}
+	if len(values) == 6 {
+		data.HasHotspot = true
+		data.HotspotX, data.HotspotY = values[4], values[5]
+		if data.HotspotX >= data.Width || data.HotspotY >= data.Height {
+			return nil, false, p.errorf(s.line, "hotspot %d,%d lies outside the %dx%d image", data.HotspotX, data.HotspotY, data.Width, data.Height)
+		}
+	}
+	return data, hasExt, nil
+}
+
+// 2. palette: "<chars> <key> <color> [<key> <color>]..."
+// keys are c (color), m (mono), g (gray), g4 (4-level gray) and s (symbolic)
+// values may span several words, e.g. "c dark slate gray"
+func (p *xpmParser) parseColor(data *XPMData, i int) error {
+	s, err := p.take(fmt.Sprintf("color %d of %d", i+1, data.NumColors))
+	if err != nil {
+		return err
+	}
+	cpp := data.CharsPerPixel
+	if len(s.text) < cpp {
+		return p.errorf(s.line, "color entry %q is shorter than %d chars per pixel", s.text, cpp)
+	}
+	chars := s.text[:cpp]
+	if _, dup := data.Visuals[chars]; dup {
+		return p.errorf(s.line, "color code %q is defined twice", chars)
+	}
+
+	words := strings.Fields(s.text[cpp:])
+	if len(words) == 0 {
+		return p.errorf(s.line, "color entry %q has no visuals", s.text)
+	}
+	visuals := make(map[string]string)
+	key := ""
+	var value []string
+	flush := func() error {
+		if key == "" {
+			return nil
+		}
+		if len(value) == 0 {
+			return p.errorf(s.line, "visual '%s' of color %q has no value", key, chars)
+		}
+		visuals[key] = strings.Join(value, " ")
+		return nil
+	}
+	for w, word := range words {
+		// a word is a key if it names a visual and either starts the entry or
+		// follows a complete value; the last word can only be a value
+		if isVisualKey(word) && (key == "" || len(value) > 0) && w < len(words)-1 {
+			if err := flush(); err != nil {
+				return err
 			}
-		} else {
-			color := strings.TrimSpace(parts[1])
-			data.Colors[charCode] = color
+			key, value = word, nil
+			continue
 		}
-		data.PaletteKeys = append(data.PaletteKeys, charCode)
+		if key == "" {
+			return p.errorf(s.line, "unknown visual %q in color %q (want c, m, g, g4 or s)", word, chars)
+		}
+		value = append(value, word)
+	}
+	if err := flush(); err != nil {
+		return err
 	}
 
-	// 3. pixels
-	for i := 0; i < h; i++ {
-		idx := 1 + nc + i
-		if idx >= len(contentLines) {
-			return nil, fmt.Errorf("not enough pixel data")
+	display, err := colors.Normalize(displayColor(visuals))
+	if err != nil {
+		return p.errorf(s.line, "color %q: %v", chars, err)
+	}
+	data.Visuals[chars] = visuals
+	data.Colors[chars] = display
+	data.PaletteKeys = append(data.PaletteKeys, chars)
+	return nil
+}
+
+func isVisualKey(word string) bool {
+	switch word {
+	case "c", "m", "g", "g4", "s":
+		return true
+	}
+	return false
+}
+
+// picks the best available visual for display
+// symbolic-only entries (colors the application fills in) become transparent
+func displayColor(visuals map[string]string) string {
+	for _, k := range visualPriority {
+		if v, ok := visuals[k]; ok {
+			return v
 		}
-		data.Pixels[i] = contentLines[idx]
 	}
+	return "None"
+}
 
-	return data, nil
-}
\ No newline at end of file
+// 3. pixels: height rows of width * cpp chars
+func (p *xpmParser) parsePixels(data *XPMData, y int) error {
+	s, err := p.take(fmt.Sprintf("pixel row %d of %d", y+1, data.Height))
+	if err != nil {
+		return err
+	}
+	cpp := data.CharsPerPixel
+	want := data.Width * cpp
+	if len(s.text) != want {
+		return p.errorf(s.line, "pixel row %d has %d chars, want %d (%d pixels * %d chars)", y+1, len(s.text), want, data.Width, cpp)
+	}
+	for x := 0; x < data.Width; x++ {
+		code := s.text[x*cpp : (x+1)*cpp]
+		if _, ok := data.Visuals[code]; !ok {
+			return p.errorf(s.line, "pixel row %d, column %d uses undefined color code %q", y+1, x+1, code)
+		}
+	}
+	data.Pixels[y] = s.text
+	return nil
+}
+
+// 4. extensions: "XPMEXT name [data]" followed by data lines, then "XPMENDEXT"
+func (p *xpmParser) parseExtensions(data *XPMData) error {
+	for {
+		s, err := p.take("XPMEXT or XPMENDEXT")
+		if err != nil {
+			return err
+		}
+		if s.text == "XPMENDEXT" {
+			return nil
+		}
+		rest, ok := strings.CutPrefix(s.text, "XPMEXT ")
+		if !ok {
+			if len(data.Extensions) == 0 {
+				return p.errorf(s.line, "expected XPMEXT, got %q", s.text)
+			}
+			last := &data.Extensions[len(data.Extensions)-1]
+			last.Lines = append(last.Lines, s.text)
+			continue
+		}
+		name, inline, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		if name == "" {
+			return p.errorf(s.line, "XPMEXT without a name")
+		}
+		ext := Extension{Name: name}
+		if inline = strings.TrimSpace(inline); inline != "" {
+			ext.Lines = append(ext.Lines, inline)
+		}
+		data.Extensions = append(data.Extensions, ext)
+	}
+}
+
+// converts the raw pixel rows into an indexed image
+// indices follow PaletteKeys order, the palette holds the display colors
+// returns: image
+func (d *XPMData) Image() *raster.Image {
+	charMap := make(map[string]int, len(d.PaletteKeys))
+	for i, k := range d.PaletteKeys {
+		charMap[k] = i
+	}
+
+	palette := make([]string, len(d.PaletteKeys))
+	for i, k := range d.PaletteKeys {
+		palette[i] = d.Colors[k]
+	}
+
+	cpp := d.CharsPerPixel
+	img := raster.New(d.Width, d.Height, palette)
+	for y, row := range d.Pixels {
+		out := img.Row(y)
+		for x := range out {
+			out[x] = uint16(charMap[row[x*cpp:(x+1)*cpp]])
+		}
+	}
+	return img
+}
@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeXPM(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		keys    []string
+		colors  []string // display colors in PaletteKeys order
+		pixels  []string
+		hotspot []int // x, y when the header has one
+		exts    []Extension
+	}{
+		{
+			name: "xpm3 with comments",
+			src: `/* XPM */
+static char *icon[] = {
+/* width height ncolors cpp */
+"3 2 2 1",
+"a c #FF0000", // trailing comment
+"b c None",
+/* pixels */
+"aba",
+"bab"
+};`,
+			keys:   []string{"a", "b"},
+			colors: []string{"#FF0000", "None"},
+			pixels: []string{"aba", "bab"},
+		},
+		{
+			name: "two chars per pixel, spaces and escaped quotes in keys",
+			src: `static char *x[] = {
+"2 1 2 2",
+"  c #000",
+"\"\\ c #FFFFFF",
+"\"\\  "
+};`,
+			keys:   []string{"  ", `"\`},
+			colors: []string{"#000000", "#FFFFFF"},
+			pixels: []string{`"\  `},
+		},
+		{
+			name: "every visual, multi word names, symbolic only",
+			src: `static char *x[] = {
+"4 1 4 1",
+"a s border c dark slate gray m black",
+"b m white g4 gray50",
+"c g #808080 m black",
+"d s background",
+"abcd"
+};`,
+			keys:   []string{"a", "b", "c", "d"},
+			colors: []string{"#2F4F4F", "#7F7F7F", "#808080", "None"},
+			pixels: []string{"abcd"},
+		},
+		{
+			name: "hotspot and extensions",
+			src: `static char *x[] = {
+"2 2 1 1 1 0 XPMEXT",
+"a c blue",
+"aa",
+"aa",
+"XPMEXT version 1.0",
+"XPMEXT notes",
+"first line",
+"second line",
+"XPMENDEXT"
+};`,
+			keys:    []string{"a"},
+			colors:  []string{"#0000FF"},
+			pixels:  []string{"aa", "aa"},
+			hotspot: []int{1, 0},
+			exts: []Extension{
+				{Name: "version", Lines: []string{"1.0"}},
+				{Name: "notes", Lines: []string{"first line", "second line"}},
+			},
+		},
+		{
+			name: "xpm2",
+			src: `! XPM2
+2 2 2 1
+! a comment line
+. c #FFFF0000FFFF
+# c rgb:0/8/f
+.#
+#.
+`,
+			keys:   []string{".", "#"},
+			colors: []string{"#FF00FF", "#0088FF"},
+			pixels: []string{".#", "#."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := DecodeXPM(strings.NewReader(tt.src), "test.xpm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(data.PaletteKeys, tt.keys) {
+				t.Errorf("keys = %q, want %q", data.PaletteKeys, tt.keys)
+			}
+			var got []string
+			for _, k := range data.PaletteKeys {
+				got = append(got, data.Colors[k])
+			}
+			if !slices.Equal(got, tt.colors) {
+				t.Errorf("colors = %v, want %v", got, tt.colors)
+			}
+			if !slices.Equal(data.Pixels, tt.pixels) {
+				t.Errorf("pixels = %q, want %q", data.Pixels, tt.pixels)
+			}
+			if tt.hotspot != nil && (!data.HasHotspot || data.HotspotX != tt.hotspot[0] || data.HotspotY != tt.hotspot[1]) {
+				t.Errorf("hotspot = %v %d,%d, want %v", data.HasHotspot, data.HotspotX, data.HotspotY, tt.hotspot)
+			}
+			if tt.hotspot == nil && data.HasHotspot {
+				t.Errorf("unexpected hotspot %d,%d", data.HotspotX, data.HotspotY)
+			}
+			if len(data.Extensions) != len(tt.exts) {
+				t.Fatalf("extensions = %v, want %v", data.Extensions, tt.exts)
+			}
+			for i, ext := range tt.exts {
+				if data.Extensions[i].Name != ext.Name || !slices.Equal(data.Extensions[i].Lines, ext.Lines) {
+					t.Errorf("extension %d = %v, want %v", i, data.Extensions[i], ext)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeXPMErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+		msg  string // part of the message
+	}{
+		{"no strings", "static char *x[] = {};", 1, "no xpm data"},
+		{"unterminated comment", "/* XPM\n\n", 1, "unterminated comment"},
+		{"unterminated string", "/* XPM */\n\"1 1 1 1\n", 2, "unterminated string"},
+		{"short header", "\"1 1 1\"", 1, "invalid header"},
+		{"bad number", "\"1 x 1 1\"", 1, "invalid height"},
+		{"zero width", "\"0 1 1 1\"", 1, "width must be positive"},
+		{"hotspot outside", "\"2 2 1 1 5 0\",\n\"a c red\",\n\"aa\",\n\"aa\"", 1, "hotspot 5,0"},
+		{"missing colors", "\"1 1 2 1\",\n\"a c red\"", 2, "expected color 2 of 2"},
+		{"unknown visual", "\"1 1 1 1\",\n\n\"a q red\",\n\"a\"", 3, "unknown visual"},
+		{"visual without value", "\"1 1 1 1\",\n\"a c\",\n\"a\"", 2, "unknown visual \"c\""},
+		{"unknown color", "\"1 1 1 1\",\n\"a c notacolor\",\n\"a\"", 2, "unknown color"},
+		{"duplicate code", "\"1 1 2 1\",\n\"a c red\",\n\"a c blue\",\n\"a\"", 3, "defined twice"},
+		{"short row", "\"2 1 1 1\",\n\"a c red\",\n\"a\"", 3, "has 1 chars, want 2"},
+		{"undefined code", "\"2 2 1 1\",\n\"a c red\",\n\"aa\",\n\"ab\"", 4, "column 2 uses undefined color code \"b\""},
+		{"missing rows", "\"1 2 1 1\",\n\"a c red\",\n\"a\"", 3, "expected pixel row 2 of 2"},
+		{"missing XPMENDEXT", "\"1 1 1 1 XPMEXT\",\n\"a c red\",\n\"a\",\n\"XPMEXT a\"", 4, "expected XPMEXT or XPMENDEXT"},
+		{"xpm2 short row", "! XPM2\n1 1 1 1\n! comment\na c red\n\n", 5, "pixel row 1 has 0 chars"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeXPM(strings.NewReader(tt.src), "bad.xpm")
+			var syntax *SyntaxError
+			if !errors.As(err, &syntax) {
+				t.Fatalf("error = %v, want a *SyntaxError", err)
+			}
+			if syntax.File != "bad.xpm" || syntax.Line != tt.line || !strings.Contains(syntax.Msg, tt.msg) {
+				t.Errorf("error = %q, want bad.xpm:%d: ...%s...", err, tt.line, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package palette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// adobe swatch exchange, all big endian:
+//
+//	"ASEF", version 1.0 (two uint16), block count (uint32)
+//	blocks: type (uint16), length (uint32), body
+//
+// a color block body is a utf-16 name with its length in code units
+// (terminating zero included), a 4 byte model ("RGB ", "CMYK", "LAB ",
+// "Gray"), one float32 per channel and a uint16 swatch type
+const (
+	aseColor      = 0x0001
+	aseGroupStart = 0xC001
+	aseGroupEnd   = 0xC002
+)
+
+// takes: file content, path for errors
+// returns: name of the first group (if any), colors, error
+func parseASE(content []byte, path string) (string, []string, error) {
+	r := bytes.NewReader(content)
+	var header struct {
+		Magic  [4]byte
+		Major  uint16
+		Minor  uint16
+		Blocks uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil || string(header.Magic[:]) != "ASEF" {
+		return "", nil, fmt.Errorf("%s: not an adobe swatch exchange file", path)
+	}
+
+	var group string
+	var list []string
+	for i := uint32(0); i < header.Blocks; i++ {
+		var kind uint16
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &kind); err != nil {
+			return "", nil, fmt.Errorf("%s: block %d: %v", path, i, err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return "", nil, fmt.Errorf("%s: block %d: %v", path, i, err)
+		}
+		if int64(length) > int64(r.Len()) {
+			return "", nil, fmt.Errorf("%s: block %d: truncated", path, i)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return "", nil, fmt.Errorf("%s: block %d: %v", path, i, err)
+		}
+		switch kind {
+		case aseGroupStart:
+			if name, _, err := aseName(body); err == nil && group == "" {
+				group = name
+			}
+		case aseColor:
+			hex, err := aseSwatch(body)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: block %d: %v", path, i, err)
+			}
+			list = append(list, hex)
+		}
+		// group ends and unknown blocks carry nothing we need
+	}
+	return group, list, nil
+}
+
+// reads the length prefixed utf-16 name at the start of a block
+// returns: name, bytes consumed, error
+func aseName(body []byte) (string, int, error) {
+	if len(body) < 2 {
+		return "", 0, fmt.Errorf("truncated name")
+	}
+	units := int(binary.BigEndian.Uint16(body))
+	end := 2 + units*2
+	if len(body) < end {
+		return "", 0, fmt.Errorf("truncated name")
+	}
+	name := make([]uint16, 0, units)
+	for i := 0; i < units; i++ {
+		if u := binary.BigEndian.Uint16(body[2+i*2:]); u != 0 {
+			name = append(name, u)
+		}
+	}
+	return string(utf16.Decode(name)), end, nil
+}
+
+// converts one color block to "#RRGGBB"
+func aseSwatch(body []byte) (string, error) {
+	_, off, err := aseName(body)
+	if err != nil {
+		return "", err
+	}
+	if len(body) < off+4 {
+		return "", fmt.Errorf("truncated color")
+	}
+	model := string(body[off : off+4])
+	off += 4
+	channels := map[string]int{"RGB ": 3, "CMYK": 4, "LAB ": 3, "Gray": 1}[model]
+	if channels == 0 {
+		return "", fmt.Errorf("unknown color model %q", model)
+	}
+	if len(body) < off+channels*4 {
+		return "", fmt.Errorf("truncated color")
+	}
+	v := make([]float64, channels)
+	for i := range v {
+		v[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(body[off+i*4:])))
+	}
+
+	var r, g, b float64
+	switch model {
+	case "RGB ":
+		r, g, b = v[0], v[1], v[2]
+	case "Gray":
+		r, g, b = v[0], v[0], v[0]
+	case "CMYK":
+		r = (1 - v[0]) * (1 - v[3])
+		g = (1 - v[1]) * (1 - v[3])
+		b = (1 - v[2]) * (1 - v[3])
+	case "LAB ":
+		// l is stored as 0-1, a and b as they are
+		r, g, b = labToRGB(v[0]*100, v[1], v[2])
+	}
+	return fmt.Sprintf("#%02X%02X%02X", unit(r), unit(g), unit(b)), nil
+}
+
+// converts cie lab (d50, as adobe uses) to gamma encoded srgb, channels 0-1
+func labToRGB(l, a, bb float64) (float64, float64, float64) {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - bb/200
+	inv := func(t float64) float64 {
+		if t*t*t > 0.008856 {
+			return t * t * t
+		}
+		return (t - 16.0/116) / 7.787
+	}
+	x, y, z := 0.96422*inv(fx), inv(fy), 0.82521*inv(fz)
+	// bradford adapted xyz (d50) -> linear srgb
+	lr := 3.1338561*x - 1.6168667*y - 0.4906146*z
+	lg := -0.9787684*x + 1.9161415*y + 0.0334540*z
+	lb := 0.0719453*x - 0.2289914*y + 1.4052427*z
+	return fromLinear(lr), fromLinear(lg), fromLinear(lb)
+}
+
+// clamps a 0-1 channel and scales it to a byte
+func unit(c float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, c)) * 255))
+}
+
+// writes every entry as a named rgb swatch inside one group named after the palette
+func formatASE(p Palette) ([]byte, error) {
+	if err := opaqueOnly(p, ".ase"); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("ASEF")
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(p.Colors)+2))
+
+	block := func(kind uint16, body []byte) {
+		binary.Write(&buf, binary.BigEndian, kind)
+		binary.Write(&buf, binary.BigEndian, uint32(len(body)))
+		buf.Write(body)
+	}
+	name := func(s string) []byte {
+		var b bytes.Buffer
+		units := append(utf16.Encode([]rune(s)), 0)
+		binary.Write(&b, binary.BigEndian, uint16(len(units)))
+		binary.Write(&b, binary.BigEndian, units)
+		return b.Bytes()
+	}
+
+	block(aseGroupStart, name(p.Name))
+	for _, c := range p.Colors {
+		r, g, b := channels(c)
+		body := bytes.NewBuffer(name(c))
+		body.WriteString("RGB ")
+		binary.Write(body, binary.BigEndian, []float32{float32(r) / 255, float32(g) / 255, float32(b) / 255})
+		binary.Write(body, binary.BigEndian, uint16(2)) // normal (not global or spot)
+		block(aseColor, body.Bytes())
+	}
+	block(aseGroupEnd, nil)
+	return buf.Bytes(), nil
+}
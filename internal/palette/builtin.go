@@ -0,0 +1,28 @@
+package palette
+
+// the palettes -palette knows by name
+// neon, creature, pastel and coral are the generators' own defaults
+var builtins = map[string][]string{
+	"neon":     {"#000000", "#39FF14", "#FF69B4", "#00FFFF", "#FFFF00", "#BF00FF"},
+	"creature": {"#000000", "#2b0000", "#660000", "#4a4a4a", "#e0e0e0", "#ffea00"},
+	"pastel":   {"#89CFF0", "#E6E6FA", "#98FF98", "#FFD1DC", "#FFDAB9", "#FFFDD0"},
+	// electric blue / cyan / magenta gradient
+	"coral": {
+		"#000000", "#000033", "#000066", "#000099", "#0000CC", "#0000FF", "#0055FF", "#00AAFF",
+		"#00FFFF", "#55FFFF", "#AAFFFF", "#FFFFFF", "#FF00FF", "#FF55FF",
+	},
+	"pico-8": {
+		"#000000", "#1D2B53", "#7E2553", "#008751", "#AB5236", "#5F574F", "#C2C3C7", "#FFF1E8",
+		"#FF004D", "#FFA300", "#FFEC27", "#00E436", "#29ADFF", "#83769C", "#FF77A8", "#FFCCAA",
+	},
+	// the four greens of the original game boy screen, darkest first
+	"gameboy": {"#0F380F", "#306230", "#8BAC0F", "#9BBC0F"},
+	"nord": {
+		"#2E3440", "#3B4252", "#434C5E", "#4C566A", "#D8DEE9", "#E5E9F0", "#ECEFF4", "#8FBCBB",
+		"#88C0D0", "#81A1C1", "#5E81AC", "#BF616A", "#D08770", "#EBCB8B", "#A3BE8C", "#B48EAD",
+	},
+	"solarized": {
+		"#002B36", "#073642", "#586E75", "#657B83", "#839496", "#93A1A1", "#EEE8D5", "#FDF6E3",
+		"#B58900", "#CB4B16", "#DC322F", "#D33682", "#6C71C4", "#268BD2", "#2AA198", "#859900",
+	},
+}
@@ -0,0 +1,62 @@
+package palette
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// gimp palette:
+//
+//	GIMP Palette
+//	Name: something
+//	Columns: 4
+//	# comment
+//	255   0   0	red
+func parseGPL(content, path string) (string, []string, error) {
+	var name string
+	var list []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			if text != "GIMP Palette" {
+				return "", nil, fmt.Errorf("%s:1: missing \"GIMP Palette\" header", path)
+			}
+			continue
+		}
+		if strings.HasPrefix(text, "Name:") {
+			name = strings.TrimSpace(strings.TrimPrefix(text, "Name:"))
+			continue
+		}
+		// color names may contain ':' too, only the Columns: header is skipped
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "Columns:") {
+			continue
+		}
+		var r, g, b int
+		if _, err := fmt.Sscanf(text, "%d %d %d", &r, &g, &b); err != nil {
+			return "", nil, fmt.Errorf("%s:%d: expected \"R G B [name]\", got %q", path, line, text)
+		}
+		if r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+			return "", nil, fmt.Errorf("%s:%d: channel out of range in %q", path, line, text)
+		}
+		list = append(list, fmt.Sprintf("#%02X%02X%02X", r, g, b))
+	}
+	return name, list, scanner.Err()
+}
+
+func formatGPL(p Palette) ([]byte, error) {
+	if err := opaqueOnly(p, ".gpl"); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "GIMP Palette\nName: %s\nColumns: 8\n#\n", p.Name)
+	for _, c := range p.Colors {
+		r, g, b := channels(c)
+		fmt.Fprintf(&buf, "%3d %3d %3d\t%s\n", r, g, b, c)
+	}
+	return buf.Bytes(), nil
+}
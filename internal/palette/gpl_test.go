@@ -0,0 +1,27 @@
+package palette
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseGPLColorNamesWithColons(t *testing.T) {
+	content := "GIMP Palette\n" +
+		"Name: Theme: dark\n" +
+		"Columns: 4\n" +
+		"# accents\n" +
+		"  0   0 255\tAccent: blue\n" +
+		"255 128   0\tWarning: orange\n" +
+		" 16  16  16\n"
+	name, colors, err := parseGPL(content, "theme.gpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Theme: dark" {
+		t.Errorf("name = %q, want %q", name, "Theme: dark")
+	}
+	want := []string{"#0000FF", "#FF8000", "#101010"}
+	if !slices.Equal(colors, want) {
+		t.Errorf("colors = %v, want %v", colors, want)
+	}
+}
@@ -0,0 +1,123 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// harmony schemes Harmony understands
+var Schemes = []string{"complementary", "analogous", "triadic", "tetradic", "split-complementary", "monochrome", "cosine"}
+
+// hue offsets in degrees from the random base hue
+var schemeHues = map[string][]float64{
+	"complementary":       {0, 180},
+	"analogous":           {-30, 0, 30},
+	"triadic":             {0, 120, 240},
+	"tetradic":            {0, 90, 180, 270},
+	"split-complementary": {0, 150, 210},
+	"monochrome":          {0},
+}
+
+// smallest oklab distance between any two colors of a harmony palette
+// about twice what most people can tell apart side by side
+const MinDistance = 0.04
+
+// lightness range the colors are spread over, dark to light
+const (
+	minLightness = 0.2
+	maxLightness = 0.92
+)
+
+// builds a random palette in oklch following a color harmony scheme
+// colors come out sorted dark to light, so index 0 makes a good background,
+// and no two of them are closer than MinDistance
+// takes: scheme (one of Schemes), number of colors, rng
+// returns: "#RRGGBB" colors, or an error if the scheme is unknown or
+// can't fit that many colors
+func Harmony(scheme string, n int, rng *rand.Rand) ([]string, error) {
+	_, known := schemeHues[scheme]
+	if !known && scheme != "cosine" {
+		return nil, fmt.Errorf("unknown harmony scheme %q", scheme)
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("a palette needs at least 1 color")
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		var labs []oklab
+		if scheme == "cosine" {
+			labs = cosineColors(n, rng)
+		} else {
+			labs = harmonyColors(schemeHues[scheme], n, rng)
+		}
+		hexes := make([]string, n)
+		for i, c := range labs {
+			hexes[i] = c.hex()
+		}
+		if spacedApart(hexes) {
+			return hexes, nil
+		}
+	}
+	return nil, fmt.Errorf("can't fit %d %s colors at least %.2f apart, try fewer", n, scheme, MinDistance)
+}
+
+// spreads n colors evenly over the lightness range and hands out the
+// scheme's hues in turn, so neighbours in lightness also differ in hue
+func harmonyColors(offsets []float64, n int, rng *rand.Rand) []oklab {
+	base := rng.Float64() * 360
+	chroma := 0.09 + rng.Float64()*0.08
+	step := (maxLightness - minLightness) / float64(n)
+	labs := make([]oklab, n)
+	for i := range labs {
+		t := (float64(i) + 0.5) / float64(n)
+		l := minLightness + t*(maxLightness-minLightness) + (rng.Float64()-0.5)*0.5*step
+		hue := base + offsets[i%len(offsets)] + (rng.Float64()-0.5)*16
+		c := chroma
+		if len(offsets) == 1 {
+			// a ramp is most colorful in the middle, like real tints and shades
+			c = chroma * (0.4 + 0.6*math.Sin(math.Pi*t))
+		}
+		labs[i] = oklch(l, c, hue)
+	}
+	return labs
+}
+
+// samples a cosine gradient a + b*cos(2*pi*(f*t + p)) on the a and b axes
+// while the lightness climbs steadily, after inigo quilez's rgb version
+func cosineColors(n int, rng *rand.Rand) []oklab {
+	var amp, freq, phase [2]float64
+	for i := range amp {
+		amp[i] = 0.05 + rng.Float64()*0.1
+		freq[i] = 0.3 + rng.Float64()*0.7
+		phase[i] = rng.Float64()
+	}
+	labs := make([]oklab, n)
+	for i := range labs {
+		t := 0.5
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		labs[i] = oklab{
+			L: minLightness + t*(maxLightness-minLightness),
+			A: amp[0] * math.Cos(2*math.Pi*(freq[0]*t+phase[0])),
+			B: amp[1] * math.Cos(2*math.Pi*(freq[1]*t+phase[1])),
+		}
+	}
+	return labs
+}
+
+// checks every pair of the final 8 bit colors against MinDistance
+func spacedApart(hexes []string) bool {
+	labs := make([]oklab, len(hexes))
+	for i, h := range hexes {
+		labs[i] = hexToOKLab(h)
+	}
+	for i := range labs {
+		for j := i + 1; j < len(labs); j++ {
+			if labs[i].distance(labs[j]) < MinDistance {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package palette
+
+import (
+	"fmt"
+	"math"
+)
+
+// a color in oklab, björn ottosson's perceptual space
+// l is lightness 0-1, a and b the green-red and blue-yellow axes;
+// euclidean distance between two colors tracks how different they look
+type oklab struct {
+	L, A, B float64
+}
+
+// converts gamma encoded srgb channels (0-1) to oklab
+func rgbToOKLab(r, g, b float64) oklab {
+	r, g, b = toLinear(r), toLinear(g), toLinear(b)
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return oklab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// converts oklab to gamma encoded srgb channels
+// the result can fall outside 0-1 for colors srgb can't show
+func (c oklab) rgb() (float64, float64, float64) {
+	l := c.L + 0.3963377774*c.A + 0.2158037573*c.B
+	m := c.L - 0.1055613458*c.A - 0.0638541728*c.B
+	s := c.L - 0.0894841775*c.A - 1.2914855480*c.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+	r := 4.0767416621*l - 3.3077115913*m + 0.2309699292*s
+	g := -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
+	b := -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+	return fromLinear(r), fromLinear(g), fromLinear(b)
+}
+
+// polar form: chroma and hue in degrees
+func oklch(l, chroma, hue float64) oklab {
+	rad := hue * math.Pi / 180
+	return oklab{L: l, A: chroma * math.Cos(rad), B: chroma * math.Sin(rad)}
+}
+
+// reports whether srgb can show the color
+func (c oklab) inGamut() bool {
+	const eps = 1e-4
+	r, g, b := c.rgb()
+	return r >= -eps && r <= 1+eps && g >= -eps && g <= 1+eps && b >= -eps && b <= 1+eps
+}
+
+// brings a color into srgb by lowering its chroma, keeping lightness and hue
+// so the result still looks like the same color, only less vivid
+func (c oklab) clip() oklab {
+	if c.inGamut() {
+		return c
+	}
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 24; i++ {
+		mid := (lo + hi) / 2
+		if (oklab{c.L, c.A * mid, c.B * mid}).inGamut() {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return oklab{c.L, c.A * lo, c.B * lo}
+}
+
+// formats the color as "#RRGGBB", clipping it into srgb first
+func (c oklab) hex() string {
+	r, g, b := c.clip().rgb()
+	return fmt.Sprintf("#%02X%02X%02X", unit(r), unit(g), unit(b))
+}
+
+// perceptual difference between two colors
+func (c oklab) distance(o oklab) float64 {
+	return math.Sqrt((c.L-o.L)*(c.L-o.L) + (c.A-o.A)*(c.A-o.A) + (c.B-o.B)*(c.B-o.B))
+}
+
+// parses "#RRGGBB" back into oklab, exactly as it will be displayed
+func hexToOKLab(hex string) oklab {
+	r, g, b := channels(hex)
+	return rgbToOKLab(float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+func toLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func fromLinear(c float64) float64 {
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
@@ -0,0 +1,150 @@
+package palette
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// a named list of colors
+// every entry is a "#RRGGBB" hex color or "None", like the rest of xpm-gen expects
+type Palette struct {
+	Name   string
+	Colors []string
+}
+
+// extensions Encode understands
+var Formats = []string{".gpl", ".txt", ".hex", ".pal", ".ase"}
+
+// checks if Save can write a file with this name
+func IsFormat(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range Formats {
+		if f == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// loads a palette file, the format follows the extension:
+// .gpl gimp, .txt paint.net, .hex lospec, .pal jasc, .ase adobe swatch exchange,
+// anything else a plain list with one color per line
+// takes: path
+// returns: palette named after the file (or the name stored in it), or error
+func Load(path string) (Palette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Palette{}, err
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	p := Palette{Name: base}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpl":
+		p.Name, p.Colors, err = parseGPL(string(content), path)
+		if p.Name == "" {
+			p.Name = base
+		}
+	case ".pal":
+		p.Colors, err = parseJASC(string(content), path)
+	case ".ase":
+		p.Name, p.Colors, err = parseASE(content, path)
+		if p.Name == "" {
+			p.Name = base
+		}
+	default:
+		// .txt and .hex are lists too, the parser knows paint.net's AARRGGBB
+		p.Colors, err = parseList(string(content), path)
+	}
+	if err != nil {
+		return Palette{}, err
+	}
+	if len(p.Colors) == 0 {
+		return Palette{}, fmt.Errorf("%s: no colors found", path)
+	}
+	return p, nil
+}
+
+// writes a palette, the format follows the extension of path like Load
+// the caller opens the file, so it can honour an overwrite policy
+// only .txt can hold transparent entries
+// takes: writer, path (for the format and errors), palette
+// returns: error for unknown extensions, transparent entries or write failures
+func Encode(w io.Writer, path string, p Palette) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	var content []byte
+	var err error
+	switch ext {
+	case ".gpl":
+		content, err = formatGPL(p)
+	case ".txt":
+		content, err = formatPaintNet(p)
+	case ".hex":
+		content, err = formatHex(p)
+	case ".pal":
+		content, err = formatJASC(p)
+	case ".ase":
+		content, err = formatASE(p)
+	default:
+		return fmt.Errorf("unknown palette format %q (want one of %s)", ext, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// looks up a built-in palette by name, falling back to a file path
+// takes: name or path
+// returns: palette or error
+func Resolve(spec string) (Palette, error) {
+	if p, ok := Builtin(spec); ok {
+		return p, nil
+	}
+	if _, err := os.Stat(spec); err != nil {
+		return Palette{}, fmt.Errorf("unknown palette %q: not a built-in (%s) or a readable file", spec, strings.Join(Names(), ", "))
+	}
+	return Load(spec)
+}
+
+// looks up a built-in palette
+// returns: a copy the caller may modify, false for unknown names
+func Builtin(name string) (Palette, bool) {
+	colors, ok := builtins[strings.ToLower(name)]
+	if !ok {
+		return Palette{}, false
+	}
+	return Palette{Name: strings.ToLower(name), Colors: append([]string(nil), colors...)}, true
+}
+
+// sorted names of the built-in palettes
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// turns "#RRGGBB" into its channels
+// callers already rejected "None"
+func channels(hex string) (r, g, b uint8) {
+	fmt.Sscanf(hex, "#%02X%02X%02X", &r, &g, &b)
+	return r, g, b
+}
+
+// fails on the first transparent entry, for formats without alpha
+func opaqueOnly(p Palette, format string) error {
+	for i, c := range p.Colors {
+		if c == "None" {
+			return fmt.Errorf("entry %d is transparent, which %s can't store (use .txt)", i, format)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package palette
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"xpm-gen/internal/colors"
+)
+
+// one color per line, blank lines and lines starting with ';' or "//" are skipped
+// covers lospec .hex (RRGGBB), paint.net .txt (AARRGGBB) and plain lists
+// of anything colors.Parse accepts
+func parseList(content, path string) ([]string, error) {
+	var list []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "//") {
+			continue
+		}
+		if argb, err := strconv.ParseUint(text, 16, 32); err == nil && len(text) == 8 {
+			// paint.net: alpha first, fully transparent becomes None
+			if argb>>24 == 0 {
+				list = append(list, "None")
+			} else {
+				list = append(list, fmt.Sprintf("#%06X", argb&0xFFFFFF))
+			}
+			continue
+		}
+		hex, err := colors.Normalize(text)
+		if err != nil && !strings.HasPrefix(text, "#") {
+			// lospec style hex without the '#'
+			hex, err = colors.Normalize("#" + text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		list = append(list, hex)
+	}
+	return list, scanner.Err()
+}
+
+// paint.net palette, AARRGGBB per line
+// paint.net itself reads at most 96 colors
+func formatPaintNet(p Palette) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "; paint.net Palette File\n; Palette Name: %s\n; Colors: %d\n", p.Name, len(p.Colors))
+	for _, c := range p.Colors {
+		if c == "None" {
+			buf.WriteString("00000000\n")
+			continue
+		}
+		r, g, b := channels(c)
+		fmt.Fprintf(&buf, "FF%02X%02X%02X\n", r, g, b)
+	}
+	return buf.Bytes(), nil
+}
+
+// lospec hex list, lowercase RRGGBB per line
+func formatHex(p Palette) ([]byte, error) {
+	if err := opaqueOnly(p, ".hex"); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, c := range p.Colors {
+		r, g, b := channels(c)
+		fmt.Fprintf(&buf, "%02x%02x%02x\n", r, g, b)
+	}
+	return buf.Bytes(), nil
+}
+
+// jasc (paint shop pro) palette:
+//
+//	JASC-PAL
+//	0100
+//	16
+//	255 0 0
+func parseJASC(content, path string) ([]string, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || strings.TrimSpace(lines[0]) != "JASC-PAL" {
+		return nil, fmt.Errorf("%s:1: missing \"JASC-PAL\" header", path)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(lines[2]))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("%s:3: expected the color count, got %q", path, strings.TrimSpace(lines[2]))
+	}
+	var list []string
+	for i := 3; i < len(lines) && len(list) < count; i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" {
+			continue
+		}
+		var r, g, b int
+		if _, err := fmt.Sscanf(text, "%d %d %d", &r, &g, &b); err != nil {
+			return nil, fmt.Errorf("%s:%d: expected \"R G B\", got %q", path, i+1, text)
+		}
+		if r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+			return nil, fmt.Errorf("%s:%d: channel out of range in %q", path, i+1, text)
+		}
+		list = append(list, fmt.Sprintf("#%02X%02X%02X", r, g, b))
+	}
+	if len(list) < count {
+		return nil, fmt.Errorf("%s: header promises %d colors, found %d", path, count, len(list))
+	}
+	return list, nil
+}
+
+func formatJASC(p Palette) ([]byte, error) {
+	if err := opaqueOnly(p, ".pal"); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "JASC-PAL\r\n0100\r\n%d\r\n", len(p.Colors))
+	for _, c := range p.Colors {
+		r, g, b := channels(c)
+		fmt.Fprintf(&buf, "%d %d %d\r\n", r, g, b)
+	}
+	return buf.Bytes(), nil
+}
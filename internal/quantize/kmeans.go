@@ -0,0 +1,47 @@
+package quantize
+
+import (
+	"image/color"
+)
+
+// most rounds of k-means before settling for the current centers
+const kmeansRounds = 30
+
+// lloyd's k-means on the distinct colors, weighted by pixel count
+// starts from the median cut palette, so the result is deterministic and
+// usually only needs a few rounds to settle
+// takes: distinct colors with counts, palette size
+// returns: at most n cluster centers
+func kmeans(samples []sample, n int) []color.NRGBA {
+	centers := medianCut(append([]sample(nil), samples...), n)
+	assign := make([]int, len(samples))
+	for round := 0; round < kmeansRounds; round++ {
+		moved := false
+		for i, s := range samples {
+			if c := Nearest(centers, s.c); round == 0 || c != assign[i] {
+				moved = true
+				assign[i] = c
+			}
+		}
+		if !moved {
+			break
+		}
+
+		// move every center to the weighted mean of its colors
+		sums := make([][4]int, len(centers))
+		for i, s := range samples {
+			sum := &sums[assign[i]]
+			sum[0] += int(s.c.R) * s.count
+			sum[1] += int(s.c.G) * s.count
+			sum[2] += int(s.c.B) * s.count
+			sum[3] += s.count
+		}
+		for i, sum := range sums {
+			if n := sum[3]; n > 0 {
+				centers[i] = color.NRGBA{R: uint8((sum[0] + n/2) / n), G: uint8((sum[1] + n/2) / n), B: uint8((sum[2] + n/2) / n), A: 255}
+			}
+			// an empty cluster keeps its old center
+		}
+	}
+	return centers
+}
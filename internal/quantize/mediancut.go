@@ -0,0 +1,100 @@
+package quantize
+
+import (
+	"image/color"
+	"sort"
+)
+
+// a group of colors that becomes one palette entry
+type box struct {
+	samples []sample
+}
+
+// channel value by index, 0 red, 1 green, 2 blue
+func channel(c color.NRGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	}
+	return c.B
+}
+
+// widest channel of the box and its range
+func (b box) widest() (int, int) {
+	best, bestRange := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, s := range b.samples {
+			v := channel(s.c, ch)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if r := int(hi) - int(lo); r > bestRange {
+			best, bestRange = ch, r
+		}
+	}
+	return best, bestRange
+}
+
+// weighted average color of the box
+func (b box) mean() color.NRGBA {
+	var r, g, bl, n int
+	for _, s := range b.samples {
+		r += int(s.c.R) * s.count
+		g += int(s.c.G) * s.count
+		bl += int(s.c.B) * s.count
+		n += s.count
+	}
+	return color.NRGBA{R: uint8((r + n/2) / n), G: uint8((g + n/2) / n), B: uint8((bl + n/2) / n), A: 255}
+}
+
+// heckbert's median cut
+// keeps splitting the box with the widest channel range at the pixel
+// weighted median of that channel until there are n boxes
+// takes: distinct colors with counts, palette size
+// returns: one averaged color per box, at most n
+func medianCut(samples []sample, n int) []color.NRGBA {
+	boxes := []box{{samples: samples}}
+	for len(boxes) < n {
+		// pick the box whose colors are spread the furthest
+		pick, pickCh, pickRange := -1, 0, 0
+		for i, b := range boxes {
+			if len(b.samples) < 2 {
+				continue
+			}
+			if ch, r := b.widest(); r > pickRange {
+				pick, pickCh, pickRange = i, ch, r
+			}
+		}
+		if pick < 0 {
+			break // every box holds a single color
+		}
+
+		b := boxes[pick]
+		sort.Slice(b.samples, func(i, j int) bool {
+			return channel(b.samples[i].c, pickCh) < channel(b.samples[j].c, pickCh)
+		})
+		total := 0
+		for _, s := range b.samples {
+			total += s.count
+		}
+		// split where half the pixels are on each side, never leaving one empty
+		cut, seen := 1, 0
+		for i, s := range b.samples {
+			seen += s.count
+			if seen*2 >= total {
+				cut = max(1, min(i+1, len(b.samples)-1))
+				break
+			}
+		}
+		boxes[pick] = box{samples: b.samples[:cut]}
+		boxes = append(boxes, box{samples: b.samples[cut:]})
+	}
+
+	palette := make([]color.NRGBA, len(boxes))
+	for i, b := range boxes {
+		palette[i] = b.mean()
+	}
+	return palette
+}
@@ -0,0 +1,185 @@
+package quantize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+
+	"xpm-gen/internal/colors"
+	"xpm-gen/internal/raster"
+)
+
+// the quantization methods Image understands
+var Methods = []string{"median-cut", "kmeans"}
+
+// how an image is turned into an indexed image
+// colors: palette size to aim for, not counting the transparent entry
+// method: one of Methods, ignored when palette is set
+// palette: fixed colors to map onto instead of building a palette
+// alpha: pixels less opaque than this (0-255) become "None"
+type Options struct {
+	Colors  int
+	Method  string
+	Palette []string
+	Alpha   uint8
+}
+
+// one distinct color of the image and how often it appears
+type sample struct {
+	c     color.NRGBA
+	count int
+}
+
+// reduces an image to an indexed one
+// transparent pixels, if any, get index 0 and the color "None"
+// takes: image, options
+// returns: indexed image with a "#RRGGBB"/"None" palette, error
+func Image(img image.Image, opts Options) (*raster.Image, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	// gather distinct opaque colors, every later step works on these
+	counts := map[color.NRGBA]int{}
+	transparent := false
+	pixels := make([][]color.NRGBA, height)
+	for y := 0; y < height; y++ {
+		pixels[y] = make([]color.NRGBA, width)
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A < opts.Alpha {
+				transparent = true
+				c = color.NRGBA{}
+			} else {
+				c.A = 255
+				counts[c]++
+			}
+			pixels[y][x] = c
+		}
+	}
+	samples := make([]sample, 0, len(counts))
+	for c, n := range counts {
+		samples = append(samples, sample{c, n})
+	}
+	// map order is random, sort so the same image always gives the same palette
+	sort.Slice(samples, func(i, j int) bool { return key(samples[i].c) < key(samples[j].c) })
+
+	var palette []color.NRGBA
+	switch {
+	case opts.Palette != nil:
+		for i, spec := range opts.Palette {
+			c, err := colors.Parse(spec)
+			if err != nil {
+				return nil, fmt.Errorf("palette entry %d: %v", i, err)
+			}
+			if c.A == 0 {
+				continue // transparency comes from the alpha threshold
+			}
+			palette = append(palette, c)
+		}
+		if len(palette) == 0 {
+			return nil, fmt.Errorf("the palette has no opaque colors")
+		}
+	case opts.Colors < 1:
+		return nil, fmt.Errorf("need at least 1 color, got %d", opts.Colors)
+	case opts.Colors >= raster.MaxColors:
+		return nil, fmt.Errorf("at most %d colors fit in an image, got %d", raster.MaxColors-1, opts.Colors)
+	case len(samples) <= opts.Colors:
+		// nothing to reduce
+		for _, s := range samples {
+			palette = append(palette, s.c)
+		}
+	case opts.Method == "median-cut":
+		palette = medianCut(coarse(samples), opts.Colors)
+	case opts.Method == "kmeans":
+		palette = kmeans(coarse(samples), opts.Colors)
+	default:
+		return nil, fmt.Errorf("unknown method %q (want one of %s)", opts.Method, strings.Join(Methods, ", "))
+	}
+
+	// index 0 is reserved for transparency when the image has any
+	first := 0
+	names := make([]string, 0, len(palette)+1)
+	if transparent {
+		first = 1
+		names = append(names, "None")
+	}
+	for _, c := range palette {
+		names = append(names, colors.Hex(c))
+	}
+
+	nearest := make(map[color.NRGBA]int, len(samples))
+	for _, s := range samples {
+		nearest[s.c] = first + Nearest(palette, s.c)
+	}
+	out := raster.New(width, height, names)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if c := pixels[y][x]; c.A != 0 {
+				out.Set(x, y, nearest[c])
+			}
+		}
+	}
+	return out, nil
+}
+
+// most distinct colors the palette search looks at
+const maxSamples = 1 << 15
+
+// merges photos' countless near-identical colors into 5 bits per channel
+// buckets so building the palette stays fast, each bucket keeps the
+// weighted mean of its colors
+func coarse(samples []sample) []sample {
+	if len(samples) <= maxSamples {
+		return samples
+	}
+	type sum struct{ r, g, b, n int }
+	buckets := map[uint32]*sum{}
+	var order []uint32
+	for _, s := range samples {
+		k := uint32(s.c.R>>3)<<10 | uint32(s.c.G>>3)<<5 | uint32(s.c.B>>3)
+		b, ok := buckets[k]
+		if !ok {
+			b = &sum{}
+			buckets[k] = b
+			order = append(order, k)
+		}
+		b.r += int(s.c.R) * s.count
+		b.g += int(s.c.G) * s.count
+		b.b += int(s.c.B) * s.count
+		b.n += s.count
+	}
+	out := make([]sample, len(order))
+	for i, k := range order {
+		b := buckets[k]
+		out[i] = sample{color.NRGBA{R: uint8(b.r / b.n), G: uint8(b.g / b.n), B: uint8(b.b / b.n), A: 255}, b.n}
+	}
+	return out
+}
+
+// index of the palette color closest to c
+func Nearest(palette []color.NRGBA, c color.NRGBA) int {
+	best, bestDist := 0, -1
+	for i, p := range palette {
+		if d := distance(p, c); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// squared rgb distance
+func distance(a, b color.NRGBA) int {
+	dr := int(a.R) - int(b.R)
+	dg := int(a.G) - int(b.G)
+	db := int(a.B) - int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func key(c color.NRGBA) uint32 {
+	return uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
@@ -0,0 +1,118 @@
+package quantize
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// a w x h image where every pixel has its own color as long as w, h <= 256
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	return img
+}
+
+func TestImageColorCount(t *testing.T) {
+	faded := gradient(32, 32)
+	faded.SetNRGBA(0, 0, color.NRGBA{R: 9, A: 10})
+	tests := []struct {
+		name    string
+		img     image.Image
+		opts    Options
+		palette int  // palette length of the result
+		none    bool // whether index 0 is "None"
+	}{
+		{"median-cut 1", gradient(64, 64), Options{Colors: 1, Method: "median-cut"}, 1, false},
+		{"median-cut 16", gradient(64, 64), Options{Colors: 16, Method: "median-cut"}, 16, false},
+		{"median-cut 255", gradient(64, 64), Options{Colors: 255, Method: "median-cut"}, 255, false},
+		{"kmeans 2", gradient(64, 64), Options{Colors: 2, Method: "kmeans"}, 2, false},
+		{"kmeans 16", gradient(64, 64), Options{Colors: 16, Method: "kmeans"}, 16, false},
+		{"kmeans 100", gradient(64, 64), Options{Colors: 100, Method: "kmeans"}, 100, false},
+		{"median-cut over the sample limit", gradient(256, 256), Options{Colors: 64, Method: "median-cut"}, 64, false},
+		{"kmeans over the sample limit", gradient(256, 256), Options{Colors: 64, Method: "kmeans"}, 64, false},
+		{"fewer colors than asked", gradient(4, 4), Options{Colors: 100, Method: "kmeans"}, 16, false},
+		{"transparent pixel adds None", faded, Options{Colors: 8, Method: "median-cut", Alpha: 128}, 9, true},
+		{"alpha under the threshold stays opaque", faded, Options{Colors: 8, Method: "kmeans", Alpha: 5}, 8, false},
+		{"fixed palette", gradient(16, 16), Options{Palette: []string{"black", "None", "white", "#F00"}}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Image(tt.img, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out.Palette) != tt.palette {
+				t.Fatalf("palette has %d colors, want %d: %v", len(out.Palette), tt.palette, out.Palette)
+			}
+			if (out.Palette[0] == "None") != tt.none {
+				t.Errorf("palette[0] = %s, want None: %v", out.Palette[0], tt.none)
+			}
+			sorted := slices.Clone(out.Palette)
+			slices.Sort(sorted)
+			if len(slices.Compact(sorted)) != len(out.Palette) {
+				t.Errorf("palette repeats colors: %v", out.Palette)
+			}
+			used := make([]bool, len(out.Palette))
+			for y := 0; y < out.Height; y++ {
+				for x := 0; x < out.Width; x++ {
+					used[out.At(x, y)] = true
+				}
+			}
+			if tt.opts.Palette == nil && slices.Contains(used, false) {
+				t.Errorf("some palette colors are never used")
+			}
+		})
+	}
+}
+
+func TestImageKeepsFewColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{A: 0})
+	img.SetNRGBA(2, 0, color.NRGBA{B: 255, A: 255})
+	for _, method := range Methods {
+		t.Run(method, func(t *testing.T) {
+			out, err := Image(img, Options{Colors: 4, Method: method, Alpha: 128})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"None", "#0000FF", "#FF0000"}; !slices.Equal(out.Palette, want) {
+				t.Errorf("palette = %v, want %v", out.Palette, want)
+			}
+			if got := []int{out.At(0, 0), out.At(1, 0), out.At(2, 0)}; !slices.Equal(got, []int{2, 0, 1}) {
+				t.Errorf("pixels = %v, want [2 0 1]", got)
+			}
+		})
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		opts Options
+		msg  string // part of the message
+	}{
+		{"empty image", image.NewNRGBA(image.Rect(0, 0, 0, 4)), Options{Colors: 4, Method: "kmeans"}, "empty"},
+		{"no colors", gradient(4, 4), Options{Colors: 0, Method: "kmeans"}, "at least 1 color"},
+		{"too many colors", gradient(4, 4), Options{Colors: 1 << 16, Method: "kmeans"}, "at most 65535"},
+		{"unknown method", gradient(4, 4), Options{Colors: 2, Method: "octree"}, "unknown method"},
+		{"bad palette entry", gradient(4, 4), Options{Palette: []string{"red", "notacolor"}}, "palette entry 1"},
+		{"only transparent palette", gradient(4, 4), Options{Palette: []string{"None"}}, "no opaque colors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Image(tt.img, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("error = %v, want one containing %q", err, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package raster holds palette-indexed images
+// every pipeline stage (generators, importer, quantizer, recolor, exporter)
+// passes these around, so the size and palette always travel with the pixels
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"xpm-gen/internal/colors"
+)
+
+// MaxColors is the biggest palette an Image can index
+const MaxColors = 1 << 16
+
+// Image is a picture made of palette indices
+// pixels are stored flat, row after row; a sub-image shares the pixels of
+// its parent, which is why rows are Stride entries apart rather than Width
+type Image struct {
+	Width, Height int
+	Stride        int
+	Pix           []uint16
+	Palette       []string // hex codes, "None" for transparent
+}
+
+// allocates an image with every pixel set to index 0
+// takes: size, palette (kept, not copied)
+// returns: image
+func New(width, height int, palette []string) *Image {
+	return &Image{
+		Width:   width,
+		Height:  height,
+		Stride:  width,
+		Pix:     make([]uint16, width*height),
+		Palette: palette,
+	}
+}
+
+// returns the palette index at x, y
+func (m *Image) At(x, y int) int { return int(m.Pix[y*m.Stride+x]) }
+
+// sets the palette index at x, y
+// mutates: image
+func (m *Image) Set(x, y, index int) { m.Pix[y*m.Stride+x] = uint16(index) }
+
+// returns row y, writes through to the image
+func (m *Image) Row(y int) []uint16 { return m.Pix[y*m.Stride : y*m.Stride+m.Width] }
+
+// sets every pixel to one index
+// mutates: image
+func (m *Image) Fill(index int) {
+	for y := 0; y < m.Height; y++ {
+		row := m.Row(y)
+		for x := range row {
+			row[x] = uint16(index)
+		}
+	}
+}
+
+// returns a view of a rectangle of the image, clipped to its bounds
+// the view starts at 0, 0 and shares pixels and palette with the image
+// takes: top left corner, size
+// returns: view (empty when the rectangle misses the image)
+func (m *Image) SubImage(x, y, width, height int) *Image {
+	r := clip(x, y, width, height, m.Width, m.Height)
+	if r.Empty() {
+		return &Image{Palette: m.Palette}
+	}
+	start := r.Min.Y*m.Stride + r.Min.X
+	end := (r.Max.Y-1)*m.Stride + r.Max.X
+	return &Image{
+		Width:   r.Dx(),
+		Height:  r.Dy(),
+		Stride:  m.Stride,
+		Pix:     m.Pix[start:end:end],
+		Palette: m.Palette,
+	}
+}
+
+// clips a rectangle to an image of the given size
+func clip(x, y, width, height, imageWidth, imageHeight int) image.Rectangle {
+	return image.Rect(x, y, x+width, y+height).Intersect(image.Rect(0, 0, imageWidth, imageHeight))
+}
+
+// returns a compact copy with its own pixels and palette
+func (m *Image) Clone() *Image {
+	out := New(m.Width, m.Height, append([]string(nil), m.Palette...))
+	for y := 0; y < m.Height; y++ {
+		copy(out.Row(y), m.Row(y))
+	}
+	return out
+}
+
+// parses the image palette into image colors
+// returns: colors or an error naming the first unparseable entry
+func (m *Image) ColorPalette() (color.Palette, error) {
+	return ParsePalette(m.Palette)
+}
+
+// parses hex codes (and "None") into image colors
+// takes: palette
+// returns: colors or an error naming the first unparseable entry
+func ParsePalette(hex []string) (color.Palette, error) {
+	palette := make(color.Palette, len(hex))
+	for i, c := range hex {
+		rgba, err := colors.Parse(c)
+		if err != nil {
+			return nil, fmt.Errorf("palette entry %d: %w", i, err)
+		}
+		palette[i] = rgba
+	}
+	return palette, nil
+}
+
+// converts to an image.Paletted with the same indices
+// returns: paletted image or an error for palettes over 256 colors or unparseable entries
+func (m *Image) Paletted() (*image.Paletted, error) {
+	if len(m.Palette) > 256 {
+		return nil, fmt.Errorf("indexed images hold at most 256 colors, palette has %d", len(m.Palette))
+	}
+	palette, err := m.ColorPalette()
+	if err != nil {
+		return nil, err
+	}
+	return m.PalettedWith(palette), nil
+}
+
+// converts to an image.Paletted using an already parsed palette
+// saves parsing the palette again for every animation frame
+// takes: parsed palette (at most 256 entries)
+// returns: paletted image
+func (m *Image) PalettedWith(palette color.Palette) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, m.Width, m.Height), palette)
+	for y := 0; y < m.Height; y++ {
+		out := img.Pix[y*img.Stride:]
+		for x, v := range m.Row(y) {
+			out[x] = uint8(v)
+		}
+	}
+	return img
+}
+
+// converts to a standard image
+// palettes that fit in 256 entries stay indexed, bigger ones become rgba
+// returns: image or an error for unparseable palette entries
+func (m *Image) Image() (image.Image, error) {
+	palette, err := m.ColorPalette()
+	if err != nil {
+		return nil, err
+	}
+	if len(palette) <= 256 {
+		return m.PalettedWith(palette), nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, m.Width, m.Height))
+	for y := 0; y < m.Height; y++ {
+		for x, v := range m.Row(y) {
+			img.Set(x, y, palette[v])
+		}
+	}
+	return img, nil
+}
+
+// builds an image from an image.Paletted, keeping the indices
+// takes: paletted image, palette for the result
+// returns: image
+func FromPaletted(p *image.Paletted, palette []string) *Image {
+	b := p.Bounds()
+	m := New(b.Dx(), b.Dy(), palette)
+	for y := 0; y < m.Height; y++ {
+		in := p.Pix[p.PixOffset(b.Min.X, b.Min.Y+y):]
+		row := m.Row(y)
+		for x := range row {
+			row[x] = uint16(in[x])
+		}
+	}
+	return m
+}
@@ -0,0 +1,277 @@
+package raster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxPixels caps the size a transform may grow an image to
+// 8192x8192, already a 128 MB xpm at two characters per pixel
+const MaxPixels = 1 << 26
+
+// one step of a transform pipeline
+// op is one of scale, crop, rotate, flip, tile
+// args: scale x/y factors, crop x/y/w/h, rotate degrees clockwise (90, 180, 270),
+// flip 0 for left/right or 1 for top/bottom, tile columns/rows
+type Transform struct {
+	Op   string
+	Args []int
+}
+
+// parses a comma separated transform list
+// e.g. "scale:4,rotate:90,tile:3x3,crop:0,0,64,64,flip:h"
+//   - scale:<n> or scale:<x>x<y>   repeats every pixel n times (nearest neighbour)
+//   - crop:<x>,<y>,<w>,<h>        keeps a rectangle, clipped to the image
+//   - rotate:<degrees>            turns clockwise by 90, 180 or 270 (-90 = 270)
+//   - flip:h or flip:v            mirrors left/right or top/bottom
+//   - tile:<n> or tile:<c>x<r>    repeats the image c times across and r times down
+//
+// numbers after a comma belong to the step before them, which is how crop
+// gets its four arguments
+// takes: transform string
+// returns: transforms in order or error
+func ParseTransforms(s string) ([]Transform, error) {
+	// regroup "crop:0", "0", "64", "64" into one step
+	var steps []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(part); err == nil && len(steps) > 0 {
+			steps[len(steps)-1] += "," + part
+			continue
+		}
+		steps = append(steps, part)
+	}
+
+	var transforms []Transform
+	for _, step := range steps {
+		op, arg, hasArg := strings.Cut(step, ":")
+		op = strings.ToLower(op)
+		if !hasArg && transformExample(op) != "" {
+			return nil, fmt.Errorf("transform %q needs an argument, e.g. %s", op, transformExample(op))
+		}
+		t, err := parseTransform(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	return transforms, nil
+}
+
+// parses the argument of one step
+func parseTransform(op, arg string) (Transform, error) {
+	t := Transform{Op: op}
+	var err error
+	switch op {
+	case "scale", "tile":
+		// n or AxB
+		t.Args, err = parseInts(arg, "x")
+		if err == nil && len(t.Args) == 1 {
+			t.Args = append(t.Args, t.Args[0])
+		}
+		if err == nil && (len(t.Args) != 2 || t.Args[0] < 1 || t.Args[1] < 1) {
+			err = fmt.Errorf("want a whole number of at least 1 or two of them as AxB")
+		}
+	case "crop":
+		t.Args, err = parseInts(arg, ",")
+		if err == nil && (len(t.Args) != 4 || t.Args[2] < 1 || t.Args[3] < 1) {
+			err = fmt.Errorf("want x,y,width,height with a size of at least 1x1")
+		}
+	case "rotate":
+		t.Args, err = parseInts(arg, ",")
+		if err == nil && len(t.Args) == 1 && t.Args[0]%90 == 0 {
+			t.Args[0] = (t.Args[0]%360 + 360) % 360
+		} else if err == nil {
+			err = fmt.Errorf("want a multiple of 90 degrees")
+		}
+	case "flip":
+		switch strings.ToLower(arg) {
+		case "h", "horizontal":
+			t.Args = []int{0}
+		case "v", "vertical":
+			t.Args = []int{1}
+		default:
+			err = fmt.Errorf("want h or v")
+		}
+	default:
+		return t, fmt.Errorf("unknown transform %q (want scale, crop, rotate, flip or tile)", op)
+	}
+	if err != nil {
+		return t, fmt.Errorf("transform %s:%s: %v", op, arg, err)
+	}
+	return t, nil
+}
+
+// splits a list of whole numbers
+func parseInts(s, sep string) ([]int, error) {
+	var out []int
+	for _, field := range strings.Split(s, sep) {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a whole number", field)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// shows how an op is written, for errors
+// returns: example or "" for unknown ops
+func transformExample(op string) string {
+	switch op {
+	case "scale":
+		return "scale:4"
+	case "crop":
+		return "crop:0,0,64,64"
+	case "rotate":
+		return "rotate:90"
+	case "flip":
+		return "flip:h"
+	case "tile":
+		return "tile:3x3"
+	}
+	return ""
+}
+
+// works out the size an image ends up with, without touching any pixels
+// lets callers reject a pipeline before spending time on generating
+// takes: starting size, transforms
+// returns: final size or an error for crops missing the image and oversized results
+func TransformedSize(width, height int, transforms []Transform) (int, int, error) {
+	for _, t := range transforms {
+		switch t.Op {
+		case "scale", "tile":
+			// each side first, so the area can't overflow
+			if t.Args[0] > MaxPixels/max(width, 1) || t.Args[1] > MaxPixels/max(height, 1) || width*t.Args[0]*height*t.Args[1] > MaxPixels {
+				return 0, 0, fmt.Errorf("%s:%dx%d would make the %dx%d image bigger than %d pixels", t.Op, t.Args[0], t.Args[1], width, height, MaxPixels)
+			}
+			width, height = width*t.Args[0], height*t.Args[1]
+		case "crop":
+			r := clip(t.Args[0], t.Args[1], t.Args[2], t.Args[3], width, height)
+			if r.Empty() {
+				return 0, 0, fmt.Errorf("crop:%d,%d,%d,%d misses the %dx%d image", t.Args[0], t.Args[1], t.Args[2], t.Args[3], width, height)
+			}
+			width, height = r.Dx(), r.Dy()
+		case "rotate":
+			// 0 (rotate:0 or rotate:360) keeps the size like 180
+			if t.Args[0] == 90 || t.Args[0] == 270 {
+				width, height = height, width
+			}
+		}
+	}
+	return width, height, nil
+}
+
+// runs a transform pipeline
+// indices are only moved around, so palette and transparency stay as they are
+// takes: image, transforms (from ParseTransforms)
+// returns: transformed image (the input itself when there is nothing to do) or error
+func (m *Image) Transform(transforms []Transform) (*Image, error) {
+	if _, _, err := TransformedSize(m.Width, m.Height, transforms); err != nil {
+		return nil, err
+	}
+	for _, t := range transforms {
+		switch t.Op {
+		case "scale":
+			m = m.Scale(t.Args[0], t.Args[1])
+		case "crop":
+			m = m.SubImage(t.Args[0], t.Args[1], t.Args[2], t.Args[3])
+		case "rotate":
+			m = m.Rotate(t.Args[0])
+		case "flip":
+			m = m.Flip(t.Args[0] == 1)
+		case "tile":
+			m = m.Tile(t.Args[0], t.Args[1])
+		}
+	}
+	return m, nil
+}
+
+// enlarges by whole factors, every pixel becomes an sx by sy block
+// returns: new image sharing the palette
+func (m *Image) Scale(sx, sy int) *Image {
+	out := New(m.Width*sx, m.Height*sy, m.Palette)
+	for y := 0; y < m.Height; y++ {
+		row := out.Row(y * sy)
+		for x, v := range m.Row(y) {
+			for i := 0; i < sx; i++ {
+				row[x*sx+i] = v
+			}
+		}
+		for i := 1; i < sy; i++ {
+			copy(out.Row(y*sy+i), row)
+		}
+	}
+	return out
+}
+
+// turns clockwise by 90, 180 or 270 degrees
+// returns: new image sharing the palette (a copy for 0)
+func (m *Image) Rotate(degrees int) *Image {
+	switch degrees {
+	case 90:
+		out := New(m.Height, m.Width, m.Palette)
+		for y := 0; y < m.Height; y++ {
+			for x, v := range m.Row(y) {
+				out.Pix[x*out.Stride+m.Height-1-y] = v
+			}
+		}
+		return out
+	case 180:
+		out := New(m.Width, m.Height, m.Palette)
+		for y := 0; y < m.Height; y++ {
+			row := out.Row(m.Height - 1 - y)
+			for x, v := range m.Row(y) {
+				row[m.Width-1-x] = v
+			}
+		}
+		return out
+	case 270:
+		out := New(m.Height, m.Width, m.Palette)
+		for y := 0; y < m.Height; y++ {
+			for x, v := range m.Row(y) {
+				out.Pix[(m.Width-1-x)*out.Stride+y] = v
+			}
+		}
+		return out
+	}
+	out := New(m.Width, m.Height, m.Palette)
+	for y := 0; y < m.Height; y++ {
+		copy(out.Row(y), m.Row(y))
+	}
+	return out
+}
+
+// mirrors left/right, or top/bottom when vertical is set
+// returns: new image sharing the palette
+func (m *Image) Flip(vertical bool) *Image {
+	out := New(m.Width, m.Height, m.Palette)
+	for y := 0; y < m.Height; y++ {
+		if vertical {
+			copy(out.Row(m.Height-1-y), m.Row(y))
+			continue
+		}
+		row := out.Row(y)
+		for x, v := range m.Row(y) {
+			row[m.Width-1-x] = v
+		}
+	}
+	return out
+}
+
+// repeats the image into a sheet of cols by rows copies
+// returns: new image sharing the palette
+func (m *Image) Tile(cols, rows int) *Image {
+	out := New(m.Width*cols, m.Height*rows, m.Palette)
+	for y := 0; y < out.Height; y++ {
+		row, src := out.Row(y), m.Row(y%m.Height)
+		for x := 0; x < out.Width; x += m.Width {
+			copy(row[x:], src)
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package raster
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTransformedSizeMatchesTransform(t *testing.T) {
+	tests := []string{
+		"rotate:0",
+		"rotate:90",
+		"rotate:180",
+		"rotate:270",
+		"rotate:360",
+		"rotate:-90",
+		"rotate:0,crop:100,0,10,10",
+		"rotate:90,crop:50,100,20,30",
+		"scale:2x3,rotate:270",
+		"tile:3x1,rotate:90,flip:v",
+		"crop:120,60,50,50,rotate:180",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			transforms, err := ParseTransforms(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := New(128, 64, []string{"#000000", "#FFFFFF"})
+			w, h, err := TransformedSize(m.Width, m.Height, transforms)
+			if err != nil {
+				t.Fatalf("TransformedSize: %v", err)
+			}
+			out, err := m.Transform(transforms)
+			if err != nil {
+				t.Fatalf("Transform: %v", err)
+			}
+			if out.Width != w || out.Height != h {
+				t.Errorf("TransformedSize says %dx%d, Transform made %dx%d", w, h, out.Width, out.Height)
+			}
+		})
+	}
+}
+
+func TestParseTransforms(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Transform
+	}{
+		{"", nil},
+		{"scale:4", []Transform{{"scale", []int{4, 4}}}},
+		{"Scale:2x3, tile:3x1", []Transform{{"scale", []int{2, 3}}, {"tile", []int{3, 1}}}},
+		{"crop:0, 8,64,32,flip:h", []Transform{{"crop", []int{0, 8, 64, 32}}, {"flip", []int{0}}}},
+		{"flip:V,flip:horizontal", []Transform{{"flip", []int{1}}, {"flip", []int{0}}}},
+		{"rotate:-90,rotate:360,rotate:450", []Transform{{"rotate", []int{270}}, {"rotate", []int{0}}, {"rotate", []int{90}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseTransforms(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			equal := func(a, b Transform) bool { return a.Op == b.Op && slices.Equal(a.Args, b.Args) }
+			if !slices.EqualFunc(got, tt.want, equal) {
+				t.Errorf("ParseTransforms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTransformsErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		msg  string // part of the message
+	}{
+		{"scale", "needs an argument, e.g. scale:4"},
+		{"crop", "needs an argument, e.g. crop:0,0,64,64"},
+		{"blur:2", "unknown transform \"blur\""},
+		{"scale:0", "at least 1"},
+		{"tile:2x", "not a whole number"},
+		{"scale:2x3x4", "two of them as AxB"},
+		{"crop:0,0,0,8", "size of at least 1x1"},
+		{"crop:1,2,3", "want x,y,width,height"},
+		{"rotate:45", "multiple of 90"},
+		{"rotate:90,180", "multiple of 90"},
+		{"flip:x", "want h or v"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseTransforms(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("error = %v, want one containing %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+// builds an image from rows of digits, each digit a palette index
+func fromRows(rows ...string) *Image {
+	m := New(len(rows[0]), len(rows), strings.Split("0123456789", ""))
+	for y, row := range rows {
+		for x, c := range row {
+			m.Set(x, y, int(c-'0'))
+		}
+	}
+	return m
+}
+
+// the digits of an image, row by row
+func rowsOf(m *Image) []string {
+	var rows []string
+	for y := 0; y < m.Height; y++ {
+		var b strings.Builder
+		for x := 0; x < m.Width; x++ {
+			b.WriteByte(byte('0' + m.At(x, y)))
+		}
+		rows = append(rows, b.String())
+	}
+	return rows
+}
+
+func TestTransformPixels(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"rotate:0", []string{"012", "345"}},
+		{"rotate:90", []string{"30", "41", "52"}},
+		{"rotate:180", []string{"543", "210"}},
+		{"rotate:270", []string{"25", "14", "03"}},
+		{"flip:h", []string{"210", "543"}},
+		{"flip:v", []string{"345", "012"}},
+		{"scale:2x1", []string{"001122", "334455"}},
+		{"scale:1x2", []string{"012", "012", "345", "345"}},
+		{"tile:2x2", []string{"012012", "345345", "012012", "345345"}},
+		{"crop:1,0,2,2", []string{"12", "45"}},
+		{"crop:2,1,5,5", []string{"5"}},
+		{"crop:-1,-1,3,2", []string{"01"}},
+		{"rotate:90,flip:h", []string{"03", "14", "25"}},
+		{"tile:2x1,crop:2,0,2,2", []string{"20", "53"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			transforms, err := ParseTransforms(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := fromRows("012", "345")
+			out, err := m.Transform(transforms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rowsOf(out); !slices.Equal(got, tt.want) {
+				t.Errorf("Transform = %q, want %q", got, tt.want)
+			}
+			if got := rowsOf(m); !slices.Equal(got, []string{"012", "345"}) {
+				t.Errorf("source changed to %q", got)
+			}
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		msg  string // part of the message
+	}{
+		{"crop:128,0,8,8", "misses the 128x64 image"},
+		{"rotate:90,crop:64,0,8,8", "misses the 64x128 image"},
+		{"scale:8192", "bigger than"},
+		{"tile:64x64,scale:2", "bigger than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			transforms, err := ParseTransforms(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = New(128, 64, []string{"#000000"}).Transform(transforms)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("error = %v, want one containing %q", err, tt.msg)
+			}
+		})
+	}
+}
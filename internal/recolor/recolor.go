@@ -0,0 +1,96 @@
+package recolor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xpm-gen/internal/colors"
+	"xpm-gen/internal/palette"
+)
+
+// describes a non-interactive recolor
+// list: replacement colors applied to palette entries in order
+// mapping: old color -> new color, both normalized to "#RRGGBB"/"None"
+// rules: adjustments applied to every entry afterwards
+type Plan struct {
+	List    []string
+	Mapping map[string]string
+	Rules   []Rule
+}
+
+// loads replacement colors from a file
+// .json is a {"old": "new"} mapping, anything else a palette file
+// (see palette.Load for the formats)
+// takes: path
+// returns: plan with List or Mapping filled, or error
+func LoadFile(path string) (*Plan, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseJSON(content, path)
+	}
+	p, err := palette.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{List: p.Colors}, nil
+}
+
+// {"#FF0000": "#00FF00", "gray50": "navy", ...}
+func parseJSON(content []byte, path string) (*Plan, error) {
+	raw := map[string]string{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	plan := &Plan{Mapping: make(map[string]string, len(raw))}
+	for from, to := range raw {
+		oldHex, err := colors.Normalize(from)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %v", path, from, err)
+		}
+		newHex, err := colors.Normalize(to)
+		if err != nil {
+			return nil, fmt.Errorf("%s: value for %q: %v", path, from, err)
+		}
+		plan.Mapping[oldHex] = newHex
+	}
+	return plan, nil
+}
+
+// recolors a palette according to the plan
+// the list is handed out to the opaque entries in order, so an icon's
+// transparent "None" entry keeps its transparency; entries not covered by
+// the list or mapping keep their color
+// takes: current palette, plan
+// returns: new palette or error for unparseable colors
+func Apply(palette []string, plan *Plan) ([]string, error) {
+	out := make([]string, len(palette))
+	next := 0
+	for i, old := range palette {
+		hex, err := colors.Normalize(old)
+		if err != nil {
+			return nil, fmt.Errorf("palette entry %d: %v", i, err)
+		}
+		if to, ok := plan.Mapping[hex]; ok {
+			hex = to
+		} else if hex != "None" {
+			if next < len(plan.List) {
+				hex = plan.List[next]
+			}
+			next++
+		}
+		if hex != "None" {
+			hex, err = applyRules(hex, plan.Rules)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out[i] = hex
+	}
+	return out, nil
+}
@@ -0,0 +1,126 @@
+package recolor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Rule
+		err  string // part of the error, "" when valid
+	}{
+		{"", nil, ""},
+		{"invert", []Rule{{Op: "invert"}}, ""},
+		{"Hue-Rotate:30, saturate:1.5,,lighten:-0.1,grayscale", []Rule{{"hue-rotate", 30}, {"saturate", 1.5}, {"lighten", -0.1}, {Op: "grayscale"}}, ""},
+		{"invert:1", nil, "takes no argument"},
+		{"saturate", nil, "needs an amount"},
+		{"lighten:much", nil, "invalid amount"},
+		{"blur:2", nil, "unknown rule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			rules, err := ParseRules(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(rules, tt.want) {
+				t.Errorf("rules = %v, want %v", rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	palette := []string{"None", "#FF0000", "gray50", "#0000FF"}
+	tests := []struct {
+		name  string
+		plan  Plan
+		rules string
+		want  []string
+	}{
+		{"empty plan keeps colors", Plan{}, "", []string{"None", "#FF0000", "#7F7F7F", "#0000FF"}},
+		{"list skips transparent entries", Plan{List: []string{"#111111", "#222222"}}, "", []string{"None", "#111111", "#222222", "#0000FF"}},
+		{"mapping wins over list", Plan{List: []string{"#111111"}, Mapping: map[string]string{"#7F7F7F": "#ABCDEF"}}, "", []string{"None", "#111111", "#ABCDEF", "#0000FF"}},
+		{"mapping can make an entry transparent", Plan{Mapping: map[string]string{"#0000FF": "None"}}, "", []string{"None", "#FF0000", "#7F7F7F", "None"}},
+		{"invert", Plan{}, "invert", []string{"None", "#00FFFF", "#808080", "#FFFF00"}},
+		{"hue-rotate wraps around", Plan{}, "hue-rotate:-120", []string{"None", "#0000FF", "#7F7F7F", "#00FF00"}},
+		{"grayscale", Plan{}, "grayscale", []string{"None", "#808080", "#7F7F7F", "#808080"}},
+		{"saturate to gray", Plan{}, "saturate:0", []string{"None", "#808080", "#7F7F7F", "#808080"}},
+		{"lighten clamps", Plan{}, "lighten:1", []string{"None", "#FFFFFF", "#FFFFFF", "#FFFFFF"}},
+		{"rules run after the list", Plan{List: []string{"#000000"}}, "invert", []string{"None", "#FFFFFF", "#808080", "#FFFF00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := tt.plan
+			var err error
+			if plan.Rules, err = ParseRules(tt.rules); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(palette, &plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		list    []string
+		mapping map[string]string
+		err     string // part of the error, "" when valid
+	}{
+		{"map.json", `{"#F00": "navy", "gray50": "None"}`, nil, map[string]string{"#FF0000": "#000080", "#7F7F7F": "None"}, ""},
+		{"bad.json", `{"#F00": 1}`, nil, nil, "bad.json"},
+		{"key.json", `{"nocolor": "red"}`, nil, nil, `key "nocolor"`},
+		{"value.json", `{"red": "nocolor"}`, nil, nil, `value for "red"`},
+		{"list.hex", "ff0000\n00ff00\n", []string{"#FF0000", "#00FF00"}, nil, ""},
+		{"list.gpl", "GIMP Palette\nName: x\n0 0 255\tblue\n", []string{"#0000FF"}, nil, ""},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			plan, err := LoadFile(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(plan.List, tt.list) {
+				t.Errorf("list = %v, want %v", plan.List, tt.list)
+			}
+			if len(plan.Mapping) != len(tt.mapping) {
+				t.Fatalf("mapping = %v, want %v", plan.Mapping, tt.mapping)
+			}
+			for from, to := range tt.mapping {
+				if plan.Mapping[from] != to {
+					t.Errorf("mapping[%s] = %q, want %q", from, plan.Mapping[from], to)
+				}
+			}
+		})
+	}
+}
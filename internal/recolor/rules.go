@@ -0,0 +1,149 @@
+package recolor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"xpm-gen/internal/colors"
+)
+
+// one adjustment applied to every palette entry
+// op is one of hue-rotate, saturate, lighten, invert, grayscale
+type Rule struct {
+	Op     string
+	Amount float64
+}
+
+// parses a comma separated rule list
+// e.g. "hue-rotate:30,saturate:1.5,lighten:-0.1,invert,grayscale"
+//   - hue-rotate:<degrees>   shifts the hue
+//   - saturate:<factor>      multiplies saturation (0 = gray, 2 = twice as vivid)
+//   - lighten:<amount>       adds to lightness, -1 to 1
+//   - invert                 flips every channel
+//   - grayscale              drops saturation entirely
+//
+// takes: rule string
+// returns: rules in order or error
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op, arg, hasArg := strings.Cut(part, ":")
+		op = strings.ToLower(op)
+		switch op {
+		case "invert", "grayscale":
+			if hasArg {
+				return nil, fmt.Errorf("rule %q takes no argument", op)
+			}
+			rules = append(rules, Rule{Op: op})
+		case "hue-rotate", "saturate", "lighten":
+			if !hasArg {
+				return nil, fmt.Errorf("rule %q needs an amount, e.g. %s:0.5", op, op)
+			}
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: invalid amount %q", op, arg)
+			}
+			rules = append(rules, Rule{Op: op, Amount: v})
+		default:
+			return nil, fmt.Errorf("unknown rule %q (want hue-rotate, saturate, lighten, invert or grayscale)", op)
+		}
+	}
+	return rules, nil
+}
+
+// runs every rule on one color
+// takes: "#RRGGBB", rules
+// returns: adjusted "#RRGGBB"
+func applyRules(hex string, rules []Rule) (string, error) {
+	if len(rules) == 0 {
+		return hex, nil
+	}
+	c, err := colors.Parse(hex)
+	if err != nil {
+		return "", err
+	}
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	for _, rule := range rules {
+		switch rule.Op {
+		case "invert":
+			r, g, b = 1-r, 1-g, 1-b
+		case "grayscale":
+			h, _, l := rgbToHSL(r, g, b)
+			r, g, b = hslToRGB(h, 0, l)
+		case "hue-rotate":
+			h, s, l := rgbToHSL(r, g, b)
+			r, g, b = hslToRGB(math.Mod(math.Mod(h+rule.Amount, 360)+360, 360), s, l)
+		case "saturate":
+			h, s, l := rgbToHSL(r, g, b)
+			r, g, b = hslToRGB(h, clamp01(s*rule.Amount), l)
+		case "lighten":
+			h, s, l := rgbToHSL(r, g, b)
+			r, g, b = hslToRGB(h, s, clamp01(l+rule.Amount))
+		}
+	}
+	return fmt.Sprintf("#%02X%02X%02X", toByte(r), toByte(g), toByte(b)), nil
+}
+
+// converts rgb (0-1) to hue (0-360), saturation and lightness (0-1)
+func rgbToHSL(r, g, b float64) (h, s, l float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l // achromatic
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, s, l
+}
+
+// converts hue (0-360), saturation and lightness (0-1) back to rgb (0-1)
+func hslToRGB(h, s, l float64) (r, g, b float64) {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
@@ -3,68 +3,162 @@ package main
 import (
 	"flag"
 	"fmt"
-	"math"
+	"io"
 	"math/rand"
 	"os"
-	"strconv"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/chzyer/readline"
+	"xpm-gen/internal/colors"
 	"xpm-gen/internal/config"
 	"xpm-gen/internal/exporter"
 	"xpm-gen/internal/generator"
-	"xpm-gen/internal/importer"
+	"xpm-gen/internal/palette"
+	"xpm-gen/internal/raster"
+	"xpm-gen/internal/recolor"
 )
 
 // version can be injected at build time via -ldflags
 var Version = "v1.0-dev"
 
-// returns an ansi string that paints the background with the given hex color
-func colorBlock(hex string) string {
-	if len(hex) != 7 || hex[0] != '#' {
+// where progress and error messages go
+// switched to stderr when the xpm itself is written to stdout
+var logOut io.Writer = os.Stdout
+
+// prints a status message to logOut
+func logf(format string, args ...any) {
+	fmt.Fprintf(logOut, format, args...)
+}
+
+// returns an ansi string that paints the background with the given color
+// accepts anything colors.Parse does, transparent or unknown colors get no block
+func colorBlock(spec string) string {
+	c, err := colors.Parse(spec)
+	if err != nil || c.A == 0 {
 		return ""
 	}
-	r, _ := strconv.ParseInt(hex[1:3], 16, 64)
-	g, _ := strconv.ParseInt(hex[3:5], 16, 64)
-	b, _ := strconv.ParseInt(hex[5:7], 16, 64)
 	// ansi truecolor background: \033[48;2;r;g;bm
-	return fmt.Sprintf("\033[48;2;%d;%d;%dm      \033[0m", r, g, b)
+	return fmt.Sprintf("\033[48;2;%d;%d;%dm      \033[0m", c.R, c.G, c.B)
 }
 
 // generates random hex palette
-// takes: size n
+// takes: rng, size n
 // returns: slice of hex strings
-func generateRandomPalette(n int) []string {
+func generateRandomPalette(rng *rand.Rand, n int) []string {
 	palette := make([]string, n)
 	for i := 0; i < n; i++ {
-		r := rand.Intn(256)
-		g := rand.Intn(256)
-		b := rand.Intn(256)
+		r := rng.Intn(256)
+		g := rng.Intn(256)
+		b := rng.Intn(256)
 		palette[i] = fmt.Sprintf("#%02X%02X%02X", r, g, b)
 	}
 	return palette
 }
 
+// -randcolors, a bool flag that optionally names a harmony scheme
+// bare -randcolors keeps the uniform rgb palette of older versions
+type randColorsFlag struct {
+	scheme string // "" when off, "rgb" or one of palette.Schemes
+}
+
+func (r *randColorsFlag) String() string { return r.scheme }
+
+func (r *randColorsFlag) Set(s string) error {
+	switch s {
+	case "false":
+		r.scheme = ""
+		return nil
+	case "true", "rgb":
+		r.scheme = "rgb"
+		return nil
+	}
+	for _, scheme := range palette.Schemes {
+		if s == scheme {
+			r.scheme = s
+			return nil
+		}
+	}
+	return fmt.Errorf("want rgb or one of %s, got %q", strings.Join(palette.Schemes, ", "), s)
+}
+
+// lets -randcolors stand alone like a plain bool flag
+func (r *randColorsFlag) IsBoolFlag() bool { return true }
+
+// collects -param name=value pairs
+// accepts comma separated lists and repeated flags
+type paramFlag map[string]string
+
+func (p paramFlag) String() string {
+	pairs := make([]string, 0, len(p))
+	for k, v := range p {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p paramFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected name=value, got %q", pair)
+		}
+		p[name] = value
+	}
+	return nil
+}
+
 // main entry point
 // orchestrates configuration, generation, and saving
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	// subcommands
+	if len(os.Args) > 1 && os.Args[1] == "check-tile" {
+		os.Exit(runCheckTile(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "convert" || os.Args[1] == "import") {
+		os.Exit(runConvert(os.Args[2:]))
+	}
 
 	// cli flags setup
 	widthPtr := flag.Int("w", 128, "Width of the texture")
 	heightPtr := flag.Int("h", 128, "Height of the texture")
-	algoPtr := flag.String("algo", "xor", "Algorithm: 'noise', 'xor', 'circles', 'mandelbrot', 'julia', 'melting', 'creature', 'pastel', 'attractor', 'cute', 'cutebunny', 'physarum', 'coral', 'random'")
-	randColorsPtr := flag.Bool("randcolors", false, "Randomize the color palette")
+	algoPtr := flag.String("algo", "xor", "Algorithm: '"+strings.Join(generator.Names(), "', '")+"', 'random' or 'list' to describe them")
+	params := paramFlag{}
+	flag.Var(params, "param", "Generator parameters, e.g. 'feed=0.037,kill=0.06,steps=5000' (see -algo list)")
+	randColors := &randColorsFlag{}
+	flag.Var(randColors, "randcolors", "Randomize the color palette: bare for uniform rgb, or -randcolors=<scheme> for an oklch harmony ("+strings.Join(palette.Schemes, ", ")+")")
+	numColorsPtr := flag.Int("ncolors", 6, "Number of colors in a -randcolors palette")
+	palettePtr := flag.String("palette", "", "Palette by name ('list' shows the built-ins) or file (.gpl, .txt, .hex, .pal, .ase); with -recolor it replaces the colors in order")
+	savePalettePtr := flag.String("save-palette", "", "Also write the palette of the texture to this file (.gpl, .txt, .hex, .pal or .ase)")
 	randomGenPtr := flag.Bool("random", false, "Generate a unique random algorithm")
-	recolorPtr := flag.String("recolor", "", "Recolor an existing XPM file (interactive)")
-	pngPtr := flag.Bool("png", false, "Convert output to PNG (requires ImageMagick)")
+	exprPtr := flag.String("expr", "", "Render an expression, e.g. 'sin(x * 3) xor abs(y - 0.5)'")
+	algoFilePtr := flag.String("algofile", "", "Render an expression saved in a .algo file")
+	recolorPtr := flag.String("recolor", "", "Recolor an existing XPM file, or every XPM in a directory (interactive unless -palette-file or -rules is given)")
+	paletteFilePtr := flag.String("palette-file", "", "Recolor without prompting: any -palette file, or .json {\"old\": \"new\"} mapping")
+	rulesPtr := flag.String("rules", "", "Recolor without prompting: e.g. 'hue-rotate:30,saturate:1.2,lighten:0.1,invert,grayscale'")
+	pngPtr := flag.Bool("png", false, "Also export a PNG (shorthand for -format png)")
+	formatPtr := flag.String("format", "", "Also export the texture as an image: 'png', 'gif' or 'bmp'")
+	outputPtr := flag.String("o", "", "Write the XPM to this exact path instead of an auto-numbered file ('-' for stdout)")
+	outDirPtr := flag.String("outdir", "", "Directory for generated files (created if missing)")
+	namePtr := flag.String("name", "", "File name template, e.g. '{algo}_{seed}_{w}x{h}' (placeholders: algo, seed, w, h, name, n)")
+	overwritePtr := flag.String("overwrite", "", "What to do when the output exists: 'unique', 'replace' or 'fail' (default: replace for -o, unique otherwise)")
+	animatePtr := flag.Bool("animate", false, "Also write an animated GIF of the simulation (coral, physarum, melting, attractor)")
+	frameEveryPtr := flag.Int("frame-every", 10, "Capture every Nth simulation step with -animate (attractor steps are 10000 iterations)")
+	delayPtr := flag.Int("delay", 5, "Delay between -animate frames in 1/100 s")
+	loopPtr := flag.Int("loop", 0, "GIF loop count with -animate: 0 loops forever, -1 plays once, N repeats N more times")
+	xpmFramesPtr := flag.Bool("xpm-frames", false, "With -animate, also write every frame as a numbered XPM")
+	tileablePtr := flag.Bool("tileable", false, "Make the texture wrap seamlessly when repeated (check with 'xpm-gen check-tile')")
+	ditherPtr := flag.String("dither", "none", "Spread values between palette steps for the smooth generators (pastel, coral, physarum, attractor, noise, expressions): '"+strings.Join(generator.DitherModes, "', '")+"'")
+	transformPtr := flag.String("transform", "", "Reshape the result keeping its palette, e.g. 'scale:4,rotate:90,flip:h,tile:3x3,crop:0,0,64,64' (also with -recolor)")
+	jobsPtr := flag.Int("j", 0, "Worker goroutines for the simulations (0 = one per CPU), output is identical for any value")
+	seedPtr := flag.Int64("seed", 0, "Random seed for reproducible output (default: picked from the clock)")
 	versionPtr := flag.Bool("version", false, "Print version information")
 
 	// custom usage message
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "xpm-gen: advanced procedural texture synthesizer\n\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n  xpm-gen [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n  xpm-gen [flags]\n  xpm-gen check-tile [-max-ratio r] [-min-differ pct] [-max-new pct] file.xpm...\n  xpm-gen convert [-colors n] [-method m] [-palette p] [-alpha a] [-transform t] [-o file] image...\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 	}
@@ -77,193 +171,225 @@ func main() {
 		os.Exit(0)
 	}
 
-	// recolor mode
-	if *recolorPtr != "" {
-		fmt.Printf("Reading %s...\n", *recolorPtr)
-		data, err := importer.ReadXPM(*recolorPtr)
-		if err != nil {
-			fmt.Printf("Error reading XPM: %v\n", err)
-			os.Exit(1)
-		}
+	out := exporter.Output{
+		Path:      *outputPtr,
+		Dir:       *outDirPtr,
+		Template:  *namePtr,
+		Overwrite: *overwritePtr,
+	}
+	if err := out.Validate(); err != nil {
+		logf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if out.Path == "-" {
+		logOut = os.Stderr
+	}
 
-		fmt.Printf("Recoloring %s (%dx%d, %d colors)\n", *recolorPtr, data.Width, data.Height, data.NumColors)
-		newColors := make([]string, len(data.PaletteKeys))
+	// image export setup
+	if *pngPtr && *formatPtr == "" {
+		*formatPtr = "png"
+	}
+	if *formatPtr != "" && !exporter.IsImageFormat(*formatPtr) {
+		logf("Error: Unknown format '%s' (want one of %s)\n", *formatPtr, strings.Join(exporter.ImageFormats, ", "))
+		os.Exit(1)
+	}
+	if !slices.Contains(generator.DitherModes, *ditherPtr) {
+		logf("Error: Unknown dither mode '%s' (want one of %s)\n", *ditherPtr, strings.Join(generator.DitherModes, ", "))
+		os.Exit(1)
+	}
+	if *jobsPtr < 0 {
+		logf("Error: -j must be 0 (one per CPU) or more\n")
+		os.Exit(1)
+	}
+	transforms, err := raster.ParseTransforms(*transformPtr)
+	if err != nil {
+		logf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *savePalettePtr != "" && !palette.IsFormat(*savePalettePtr) {
+		logf("Error: -save-palette wants one of %s, got '%s'\n", strings.Join(palette.Formats, ", "), *savePalettePtr)
+		os.Exit(1)
+	}
+	if *palettePtr == "list" {
+		printPalettes()
+		os.Exit(0)
+	}
+	if *formatPtr != "" && *outputPtr == "-" {
+		logf("Error: -format writes next to the XPM file and can't be combined with -o -\n")
+		os.Exit(1)
+	}
 
-		// initialize readline
-		rl, err := readline.New("")
-		if err != nil {
-			fmt.Printf("Error initializing readline: %v\n", err)
+	// animation setup
+	if *xpmFramesPtr && !*animatePtr {
+		*animatePtr = true
+	}
+	if *animatePtr {
+		if *outputPtr == "-" {
+			logf("Error: -animate writes next to the XPM file and can't be combined with -o -\n")
 			os.Exit(1)
 		}
-		defer rl.Close()
-
-		for i, charCode := range data.PaletteKeys {
-			oldColor := data.Colors[charCode]
-			preview := colorBlock(oldColor)
-			
-			prompt := fmt.Sprintf("Color %d: %s %s (mapped to '%s') -> New hex, 'random', or 'keep' [Enter to keep]: ", i+1, preview, oldColor, charCode)
-			rl.SetPrompt(prompt)
-			
-			line, err := rl.Readline()
-			if err != nil { // eof or ctrl+c
-				break
-			}
-			
-			input := strings.TrimSpace(line)
-			if input == "" || strings.ToLower(input) == "keep" {
-				newColors[i] = oldColor
-			} else if strings.ToLower(input) == "random" {
-				// generate random hex
-				r := rand.Intn(256)
-				g := rand.Intn(256)
-				b := rand.Intn(256)
-				newColors[i] = fmt.Sprintf("#%02X%02X%02X", r, g, b)
-				fmt.Printf(" -> Randomly picked: %s\n", newColors[i])
-			} else {
-				// auto-prepend '#' if missing
-				if !strings.HasPrefix(input, "#") && len(input) == 6 {
-					input = "#" + input
-				}
-				newColors[i] = input
-			}
+		if *frameEveryPtr < 1 || *delayPtr < 0 || *loopPtr < -1 {
+			logf("Error: -frame-every must be at least 1, -delay at least 0 and -loop at least -1\n")
+			os.Exit(1)
 		}
+	}
 
-		// reconstruct grid
-		fmt.Println("Reconstructing grid...")
-		charMap := make(map[string]int)
-		for i, k := range data.PaletteKeys {
-			charMap[k] = i
+	// seed setup
+	// only fall back to the clock if -seed was not given, so 0 stays a valid seed
+	seed := time.Now().UnixNano()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seed = *seedPtr
 		}
+	})
+	rng := rand.New(rand.NewSource(seed))
 
-		grid := make([][]int, data.Height)
-		for y, row := range data.Pixels {
-			grid[y] = make([]int, data.Width)
-			for x := 0; x < data.Width; x++ {
-				start := x * data.CharsPerPixel
-				end := start + data.CharsPerPixel
-				if end > len(row) {
-					// safe fallback
-				end = len(row)
+	// recolor mode
+	if *recolorPtr != "" {
+		var plan *recolor.Plan
+		if *paletteFilePtr != "" && *palettePtr != "" {
+			logf("Error: -palette and -palette-file can't be combined\n")
+			os.Exit(1)
+		}
+		if *paletteFilePtr != "" || *palettePtr != "" || *rulesPtr != "" {
+			plan = &recolor.Plan{}
+			if *paletteFilePtr != "" {
+				loaded, err := recolor.LoadFile(*paletteFilePtr)
+				if err != nil {
+					logf("Error reading palette file: %v\n", err)
+					os.Exit(1)
 				}
-			char := row[start:end]
-			if idx, ok := charMap[char]; ok {
-				grid[y][x] = idx
-			} else {
-				grid[y][x] = 0 // default to 0 if unknown
+				plan = loaded
 			}
+			if *palettePtr != "" {
+				p, err := palette.Resolve(*palettePtr)
+				if err != nil {
+					logf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				plan.List = p.Colors
 			}
-		}
-
-		// create config for exporter
-		cfg := config.Config{
-			Width:     data.Width,
-			Height:    data.Height,
-			Algorithm: "recolored",
-			Colors:    newColors,
-			Chars:     data.PaletteKeys,
-		}
-
-		// export
-		xpmContent := exporter.GridToXPM(grid, cfg)
-		// we'll use the original filename base + _recolored
-		fileName := exporter.SaveUniqueFile("recolored", xpmContent)
-		fmt.Printf("Success! Generated %s\n", fileName)
-		
-		if *pngPtr {
-			if err := exporter.ConvertToPNG(fileName); err != nil {
-				fmt.Printf("Error converting to PNG: %v\n", err)
-			} else {
-				fmt.Printf("Success! PNG created.\n")
+			rules, err := recolor.ParseRules(*rulesPtr)
+			if err != nil {
+				logf("Error: %v\n", err)
+				os.Exit(1)
 			}
+			plan.Rules = rules
 		}
 
+		if err := runRecolor(*recolorPtr, plan, transforms, rng, out, *formatPtr); err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	// validation
-	validAlgos := map[string]bool{
-		"noise": true, "xor": true, "circles": true,
-		"mandelbrot": true, "julia": true, "melting": true,
-		"creature": true, "pastel": true, "attractor": true,
-		"cute": true, "cutebunny": true, "physarum": true, "coral": true,
-		"random_gen": true, // allow our internal name
+	if *algoPtr == "list" {
+		printAlgorithms()
+		os.Exit(0)
 	}
 
 	if *algoPtr == "random" {
-		keys := make([]string, 0, len(validAlgos))
-		for k := range validAlgos {
-			if k != "random_gen" { // exclude the internal one from selection
-				keys = append(keys, k)
+		names := generator.Names()
+		*algoPtr = names[rng.Intn(len(names))]
+	}
+
+	// expression mode: parse -expr / -algofile up front so typos fail fast
+	var expr generator.Expression
+	if *exprPtr != "" || *algoFilePtr != "" {
+		src := *exprPtr
+		if *algoFilePtr != "" {
+			content, err := os.ReadFile(*algoFilePtr)
+			if err != nil {
+				logf("Error reading algorithm file: %v\n", err)
+				os.Exit(1)
 			}
+			src = strings.TrimSpace(string(content))
+		}
+		var err error
+		expr, err = generator.ParseExpression(src)
+		if err != nil {
+			logf("Error parsing expression: %v\n", err)
+			if perr, ok := err.(*generator.ParseError); ok {
+				// show the offending column under the source
+				logf("  %s\n  %s^\n", src, strings.Repeat(" ", perr.Col-1))
+			}
+			os.Exit(1)
 		}
-		*algoPtr = keys[rand.Intn(len(keys))]
 	}
 
-	if !validAlgos[*algoPtr] && !*randomGenPtr {
-		fmt.Printf("Error: Unknown algorithm '%s'\n", *algoPtr)
+	// validation
+	gen, ok := generator.Lookup(*algoPtr)
+	if !ok && !*randomGenPtr && expr == nil {
+		logf("Error: Unknown algorithm '%s' (try -algo list)\n", *algoPtr)
+		os.Exit(1)
+	}
+	if ok {
+		if err := generator.CheckParams(gen, params); err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *animatePtr && (!ok || !gen.Animated() || *randomGenPtr || expr != nil) {
+		logf("Error: -animate only works with the simulations (%s)\n", strings.Join(animatedNames(), ", "))
 		os.Exit(1)
 	}
 
 	// palette setup
-	colors := []string{"#000000", "#39FF14", "#FF69B4", "#00FFFF", "#FFFF00", "#BF00FF"}
-	chars := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p"}
-
-	if *algoPtr == "creature" {
-		colors = []string{"#000000", "#2b0000", "#660000", "#4a4a4a", "#e0e0e0", "#ffea00"}
-	} else if *algoPtr == "pastel" {
-		colors = []string{"#89CFF0", "#E6E6FA", "#98FF98", "#FFD1DC", "#FFDAB9", "#FFFDD0"}
-	} else if *algoPtr == "attractor" {
-		colors = []string{"#000000", "#111122", "#004488", "#0088CC", "#00FFFF", "#FFFFFF"}
-	} else if *algoPtr == "cute" {
-		// procedural color harmony (hsv)
-		baseHue := float64(rand.Intn(360))
-
-		// body: base hue, low sat (50), high val (95) -> gives us that pastel look
-		bodyColor := hsvToHex(baseHue, 50, 95)
-
-		// eyes: complementary hue (+180), high sat (80), med val (50) -> high contrast to pop out
-		eyeHue := math.Mod(baseHue+180, 360)
-		eyeColor := hsvToHex(eyeHue, 80, 50)
-
-		// background: transparent
-		bgColor := "None"
-
-		colors = []string{bgColor, bodyColor, eyeColor}
-	} else if *algoPtr == "cutebunny" {
-		// soft whites, pinks, browns
-		palettes := [][]string{
-			{"None", "#FFFFFF", "#FF69B4"}, // white bunny, pink eyes
-			{"None", "#FFC0CB", "#000000"}, // pink bunny, black eyes
-			{"None", "#D2B48C", "#5C4033"}, // brown bunny, dark eyes
-			{"None", "#E6E6FA", "#4B0082"}, // lavender bunny, indigo eyes
+	// the generator knows which colors suit it, the expression mode uses neon
+	neon, _ := palette.Builtin("neon")
+	colors := neon.Colors
+
+	if ok {
+		colors = gen.Palette(rng)
+	}
+
+	if *palettePtr != "" {
+		if randColors.scheme != "" {
+			logf("Error: -palette and -randcolors can't be combined\n")
+			os.Exit(1)
 		}
-		colors = palettes[rand.Intn(len(palettes))]
-	} else if *algoPtr == "physarum" {
-		// generate a random neon gradient
-		// black -> dark color -> bright color -> white
-		baseHue := rand.Float64() * 360.0
-		colors = make([]string, 16)
-		colors[0] = "#000000" // background
-		for i := 1; i < 16; i++ {
-			// ramp up value and saturation
-			t := float64(i) / 15.0
-			// hue shifts slightly for interest
-			h := math.Mod(baseHue + (t * 30.0), 360.0)
-			s := 100.0 - (t * 20.0) // desaturate slightly towards white
-			v := 30.0 + (t * 70.0)  // get brighter
-			
-			// push the last few colors to pure white
-			if i > 13 { s = 0; v = 100 }
-			
-			colors[i] = hsvToHex(h, s, v)
+		p, err := palette.Resolve(*palettePtr)
+		if err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ok && len(p.Colors) < gen.MinColors() {
+			logf("Error: %s needs at least %d colors, palette '%s' has %d\n", gen.Name(), gen.MinColors(), *palettePtr, len(p.Colors))
+			os.Exit(1)
+		}
+		colors = p.Colors
+	}
+
+	if randColors.scheme != "" {
+		// generators that write fixed indices need enough colors for them
+		if ok && *numColorsPtr < gen.MinColors() {
+			logf("Error: -ncolors must be at least %d for %s\n", gen.MinColors(), gen.Name())
+			os.Exit(1)
+		}
+		if *numColorsPtr < 1 {
+			logf("Error: -ncolors must be at least 1\n")
+			os.Exit(1)
+		}
+		if randColors.scheme == "rgb" {
+			colors = generateRandomPalette(rng, *numColorsPtr)
+		} else {
+			var err error
+			colors, err = palette.Harmony(randColors.scheme, *numColorsPtr, rng)
+			if err != nil {
+				logf("Error: %v\n", err)
+				os.Exit(1)
+			}
 		}
-	} else if *algoPtr == "coral" {
-		// electric blue / cyan / magenta gradient
-		colors = []string{"#000000", "#000033", "#000066", "#000099", "#0000CC", "#0000FF", "#0055FF", "#00AAFF", "#00FFFF", "#55FFFF", "#AAFFFF", "#FFFFFF", "#FF00FF", "#FF55FF"}
 	}
 
-	if *randColorsPtr {
-		colors = generateRandomPalette(6)
+	if ok {
+		var err error
+		colors, err = generator.ExpandPalette(gen, colors, params)
+		if err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	cfg := config.Config{
@@ -271,70 +397,200 @@ func main() {
 		Height:    *heightPtr,
 		Algorithm: *algoPtr,
 		Colors:    colors,
-		Chars:     chars,
+		Seed:      seed,
+		Params:    params,
+		Tileable:  *tileablePtr,
+		Dither:    *ditherPtr,
+		Workers:   *jobsPtr,
+	}
+	// a pipeline that can't work on this size fails before anything is generated
+	if _, _, err := raster.TransformedSize(cfg.Width, cfg.Height, transforms); err != nil {
+		logf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	var grid [][]int
+	var anim *exporter.Animation
+	if *animatePtr {
+		var err error
+		anim, err = exporter.NewAnimation(cfg.Colors, *delayPtr, *loopPtr)
+		if err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.FrameEvery = *frameEveryPtr
+		cfg.OnFrame = func(step int, img *raster.Image) {
+			// frames are the size that passed the check above, so this can't fail
+			frame, _ := img.Transform(transforms)
+			anim.Add(frame)
+		}
+	}
+
+	var img *raster.Image
+	var algoString string // the -random expression, saved next to the xpm
 	
 	if *randomGenPtr {
 		cfg.Algorithm = "random_gen"
 		// generate a new random expression
-		expr := generator.GenerateRandomExpression(5 + rand.Intn(5)) // depth 5-10
-		algoString := expr.String()
-		fmt.Printf("Generated Algorithm: %s\n", algoString)
-		
-		// save the algorithm to a file
-		// use a timestamp to ensure uniqueness and match the image filename pattern approximately
-		timestamp := time.Now().Unix()
-		algoFilename := fmt.Sprintf("xpmgen_random_%d.algo", timestamp)
-		if err := os.WriteFile(algoFilename, []byte(algoString), 0644); err != nil {
-			fmt.Printf("Error saving algorithm file: %v\n", err)
-		} else {
-			fmt.Printf("Saved algorithm to %s\n", algoFilename)
-		}
-
-		// generate the grid using this expression
-		grid = generator.GenerateFromExpression(cfg, expr)
+		expr := generator.GenerateRandomExpression(rng, 5+rng.Intn(5)) // depth 5-10
+		algoString = expr.String()
+		logf("Generated Algorithm: %s\n", algoString)
+
+		// generate the image using this expression
+		img = generator.GenerateFromExpression(cfg, expr)
+	} else if expr != nil {
+		cfg.Algorithm = "expr"
+		logf("Rendering %dx%d expression: %s\n", cfg.Width, cfg.Height, expr.String())
+		img = generator.GenerateFromExpression(cfg, expr)
 	} else {
-		fmt.Printf("Generating %dx%d texture using '%s'\n", cfg.Width, cfg.Height, cfg.Algorithm)
+		logf("Generating %dx%d texture using '%s'\n", cfg.Width, cfg.Height, cfg.Algorithm)
 		// execute pipeline
-		grid = generator.GenerateGrid(cfg)
+		var err error
+		img, err = generator.GenerateImage(cfg)
+		if err != nil {
+			logf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	img, err = img.Transform(transforms)
+	if err != nil {
+		logf("Error: %v\n", err)
+		os.Exit(1)
 	}
+	// {w} and {h} in file names describe the saved image
+	cfg.Width, cfg.Height = img.Width, img.Height
 
-	xpmContent := exporter.GridToXPM(grid, cfg)
-	fileName := exporter.SaveUniqueFile(cfg.Algorithm, xpmContent)
+	fileName, err := exporter.SaveXPM(out, exporter.TemplateVars(cfg), img, nil)
+	if err != nil {
+		logf("Error writing XPM: %v\n", err)
+		os.Exit(1)
+	}
 
-	fmt.Printf("Success! Generated %s\n", fileName)
+	logf("Success! Generated %s\n", fileName)
+	logf("Seed: %d (reproduce with -seed %d)\n", seed, seed)
 
-	if *pngPtr {
-		if err := exporter.ConvertToPNG(fileName); err != nil {
-			fmt.Printf("Error converting to PNG: %v\n", err)
-		} else {
-			fmt.Printf("Success! PNG created.\n")
+	if algoString != "" {
+		saveAlgo(out, fileName, algoString)
+	}
+	if *formatPtr != "" {
+		exportImage(out, fileName, img, *formatPtr)
+	}
+	if *savePalettePtr != "" {
+		savePalette(out, *savePalettePtr, fileName, cfg)
+	}
+	if anim != nil {
+		exportAnimation(out, fileName, anim, *xpmFramesPtr)
+	}
+}
+
+// writes the -random expression next to the xpm as <name>.algo and reports the result
+// nothing is written for stdout, the expression was already logged
+func saveAlgo(out exporter.Output, fileName, algo string) {
+	if fileName == "-" {
+		return
+	}
+	f, algoName, err := out.CreateBeside(fileName, ".algo")
+	if err != nil {
+		logf("Error saving algorithm file: %v\n", err)
+		return
+	}
+	_, err = f.WriteString(algo)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logf("Error saving algorithm file: %v\n", err)
+		return
+	}
+	logf("Saved algorithm to %s (render it again with -algofile)\n", algoName)
+}
+
+// writes the image as a png/gif/bmp next to the xpm and reports the result
+func exportImage(out exporter.Output, fileName string, img *raster.Image, format string) {
+	imgName, err := exporter.ExportImage(out, fileName, img, format)
+	if err != nil {
+		logf("Error exporting %s: %v\n", strings.ToUpper(format), err)
+		return
+	}
+	logf("Success! Generated %s\n", imgName)
+}
+
+// writes the captured frames as a gif (and numbered xpms) and reports the result
+func exportAnimation(out exporter.Output, fileName string, anim *exporter.Animation, xpmFrames bool) {
+	gifName, err := anim.SaveGIF(out, fileName)
+	if err != nil {
+		logf("Error exporting animation: %v\n", err)
+		return
+	}
+	logf("Success! Generated %s (%d frames)\n", gifName, anim.Len())
+	if xpmFrames {
+		pattern, err := anim.SaveFrames(out, fileName)
+		if err != nil {
+			logf("Error exporting frames: %v\n", err)
+			return
 		}
+		logf("Success! Generated %s\n", pattern)
 	}
 }
 
-// just a helper to convert hsv values to a hex string
-func hsvToHex(h, s, v float64) string {
-	s /= 100
-	v /= 100
-	c := v * s
-	x := c * (1 - math.Abs(math.Mod(h/60.0, 2)-1))
-	m := v - c
-	var r, g, b float64
-	if 0 <= h && h < 60 {
-		r, g, b = c, x, 0
-	} else if 60 <= h && h < 120 {
-		r, g, b = x, c, 0
-	} else if 120 <= h && h < 180 {
-		r, g, b = 0, c, x
-	} else if 180 <= h && h < 240 {
-		r, g, b = 0, x, c
-	} else if 240 <= h && h < 300 {
-		r, g, b = x, 0, c
-	} else {
-		r, g, b = c, 0, x
+// writes the texture's palette, named after the xpm, and reports the result
+// goes through the same overwrite policy as the xpm
+func savePalette(out exporter.Output, path, fileName string, cfg config.Config) {
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	if fileName == "-" {
+		name = cfg.Algorithm
+	}
+	f, path, err := out.CreateFile(path)
+	if err != nil {
+		logf("Error saving palette: %v\n", err)
+		return
+	}
+	err = palette.Encode(f, path, palette.Palette{Name: name, Colors: cfg.Colors})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// e.g. transparent entries the format can't hold, don't leave an empty file
+		os.Remove(path)
+		logf("Error saving palette: %v\n", err)
+		return
+	}
+	logf("Success! Generated %s\n", path)
+}
+
+// names of the generators that can be animated
+func animatedNames() []string {
+	var names []string
+	for _, g := range generator.All() {
+		if g.Animated() {
+			names = append(names, g.Name())
+		}
+	}
+	return names
+}
+
+// prints the built-in palettes with a swatch of every color
+func printPalettes() {
+	for _, name := range palette.Names() {
+		p, _ := palette.Builtin(name)
+		var swatch strings.Builder
+		for _, c := range p.Colors {
+			swatch.WriteString(colorBlock(c))
+		}
+		fmt.Printf("%-12s %2d colors  %s\n", name, len(p.Colors), swatch.String())
+	}
+}
+
+// prints every registered algorithm with its description and parameters
+func printAlgorithms() {
+	for _, g := range generator.All() {
+		fmt.Printf("%-12s %s\n", g.Name(), g.Description())
+		for _, p := range g.Params() {
+			usage := p.Usage
+			if p.Kind == generator.ParamChoice {
+				usage += " (" + strings.Join(p.Choices, ", ") + ")"
+			}
+			fmt.Printf("%-12s   %s=%s  %s\n", "", p.Name, p.Default, usage)
+		}
 	}
-	return fmt.Sprintf("#%02X%02X%02X", int((r+m)*255), int((g+m)*255), int((b+m)*255))
 }
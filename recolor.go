@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"xpm-gen/internal/colors"
+	"xpm-gen/internal/config"
+	"xpm-gen/internal/exporter"
+	"xpm-gen/internal/importer"
+	"xpm-gen/internal/raster"
+	"xpm-gen/internal/recolor"
+)
+
+// recolors one xpm, or every xpm in a directory
+// prompts for each color when plan is nil, otherwise applies the plan
+// takes: file or directory, plan (nil for interactive), transforms for -transform, rng, output settings, image format
+// returns: error or nil
+// mutates: filesystem (writes recolored files)
+func runRecolor(target string, plan *recolor.Plan, transforms []raster.Transform, rng *rand.Rand, out exporter.Output, format string) error {
+	files := []string{target}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(target, "*.xpm"))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no .xpm files in %s", target)
+		}
+		sort.Strings(files)
+		if plan == nil {
+			return fmt.Errorf("recoloring a directory needs -palette-file or -rules")
+		}
+		if out.Path != "" {
+			return fmt.Errorf("-o names a single file, use -outdir and -name when recoloring a directory")
+		}
+	}
+	if out.Path == "" && out.Template == "" {
+		out.Template = "xpmgen_{name}_recolored_{n}"
+	}
+
+	for _, file := range files {
+		if err := recolorFile(file, plan, transforms, rng, out, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recolors a single xpm and saves the result next to the others
+func recolorFile(file string, plan *recolor.Plan, transforms []raster.Transform, rng *rand.Rand, out exporter.Output, format string) error {
+	logf("Reading %s...\n", file)
+	data, err := importer.ReadXPM(file)
+	if err != nil {
+		return fmt.Errorf("reading XPM: %w", err)
+	}
+	// reshaped first so a -transform that doesn't fit fails before any prompting
+	img, err := data.Image().Transform(transforms)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	logf("Recoloring %s (%dx%d, %d colors)\n", file, data.Width, data.Height, data.NumColors)
+	oldColors := make([]string, len(data.PaletteKeys))
+	for i, k := range data.PaletteKeys {
+		oldColors[i] = data.Colors[k]
+	}
+
+	var newColors []string
+	if plan != nil {
+		newColors, err = recolor.Apply(oldColors, plan)
+	} else {
+		newColors, err = promptColors(data, oldColors, rng)
+	}
+	if err != nil {
+		return err
+	}
+
+	// same pixels, new colors
+	img.Palette = newColors
+
+	// create config for exporter
+	cfg := config.Config{
+		Width:     img.Width,
+		Height:    img.Height,
+		Algorithm: "recolored",
+		Colors:    newColors,
+	}
+
+	// export
+	// {name} is the original filename base
+	vars := exporter.TemplateVars(cfg)
+	vars["name"] = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	fileName, err := exporter.SaveXPM(out, vars, img, data.PaletteKeys)
+	if err != nil {
+		return fmt.Errorf("writing XPM: %w", err)
+	}
+	logf("Success! Generated %s\n", fileName)
+
+	if format != "" && fileName != "-" {
+		exportImage(out, fileName, img, format)
+	}
+	return nil
+}
+
+// asks for a new color for every palette entry through readline
+// takes: xpm data, current colors, rng (for 'random')
+// returns: new colors (unanswered entries keep their color)
+func promptColors(data *importer.XPMData, oldColors []string, rng *rand.Rand) ([]string, error) {
+	newColors := append([]string(nil), oldColors...)
+
+	// initialize readline
+	rl, err := readline.New("")
+	if err != nil {
+		return nil, fmt.Errorf("initializing readline: %w", err)
+	}
+	defer rl.Close()
+
+	for i := 0; i < len(data.PaletteKeys); i++ {
+		charCode := data.PaletteKeys[i]
+		oldColor := oldColors[i]
+		preview := colorBlock(oldColor)
+
+		prompt := fmt.Sprintf("Color %d: %s %s (mapped to '%s') -> New color (hex or X11 name), 'random', or 'keep' [Enter to keep]: ", i+1, preview, oldColor, charCode)
+		rl.SetPrompt(prompt)
+
+		line, err := rl.Readline()
+		if err != nil { // eof or ctrl+c
+			break
+		}
+
+		input := strings.TrimSpace(line)
+		if input == "" || strings.ToLower(input) == "keep" {
+			newColors[i] = oldColor
+		} else if strings.ToLower(input) == "random" {
+			// generate random hex
+			r := rng.Intn(256)
+			g := rng.Intn(256)
+			b := rng.Intn(256)
+			newColors[i] = fmt.Sprintf("#%02X%02X%02X", r, g, b)
+			logf(" -> Randomly picked: %s\n", newColors[i])
+		} else {
+			// auto-prepend '#' if missing
+			if !strings.HasPrefix(input, "#") && len(input) == 6 {
+				if _, err := strconv.ParseUint(input, 16, 32); err == nil {
+					input = "#" + input
+				}
+			}
+			hex, err := colors.Normalize(input)
+			if err != nil {
+				logf(" -> %v, try again\n", err)
+				i-- // ask for the same color again
+				continue
+			}
+			newColors[i] = hex
+		}
+	}
+	return newColors, nil
+}